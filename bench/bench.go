@@ -0,0 +1,349 @@
+// Package bench 把 --benchmark 模式的求值结果整理成带百分位延迟、内存分配与
+// CEL cost 信息的结构化报告，支持文本、JSON、CSV 三种输出，便于接入 CI 做
+// 回归追踪。
+package bench
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker"
+
+	"github.com/Hlinbit/cel-validation/workerpool"
+)
+
+// Options 控制一轮 benchmark 的运行方式。
+type Options struct {
+	Duration   time.Duration // 每个表达式至少运行这么久，对应 go test 的 -benchtime
+	CPUProfile string        // 非空时把整轮 benchmark 的 CPU profile 写到这个文件
+	MemProfile string        // 非空时在 benchmark 结束后把堆快照写到这个文件
+	MaxCosts   []*uint64     // 与 asts 一一对应的 per-expression cost 预算，nil 表示不设限
+	Workers    int           // 每个表达式内部并发求值 (object, program) 的 goroutine 数，<=0 时用 runtime.NumCPU()
+}
+
+// ExprStat 汇总单个表达式在所有 object 上反复求值得到的统计信息。
+type ExprStat struct {
+	Index         int           `json:"index"`
+	Expression    string        `json:"expression"`
+	Runs          int           `json:"runs"`
+	Min           time.Duration `json:"min_ns"`
+	Median        time.Duration `json:"median_ns"`
+	P95           time.Duration `json:"p95_ns"`
+	P99           time.Duration `json:"p99_ns"`
+	Max           time.Duration `json:"max_ns"`
+	AllocsPerOp   uint64        `json:"allocs_per_op"`
+	BytesPerOp    uint64        `json:"bytes_per_op"`
+	StaticMinCost uint64        `json:"static_min_cost"`
+	StaticMaxCost uint64        `json:"static_max_cost"`
+	ActualCost    uint64        `json:"actual_cost"`
+	Exceeded      bool          `json:"cost_exceeded"`
+}
+
+// Report 是一整轮 benchmark（所有表达式）的结果。
+type Report struct {
+	TotalDuration time.Duration `json:"total_duration_ns"`
+	Stats         []ExprStat    `json:"stats"`
+}
+
+// zeroEstimator 是一个不掌握任何字段大小信息的 checker.CostEstimator：离线
+// 工具拿不到真实的 object/params schema，只能让 cel-go 对未知大小使用默认值。
+type zeroEstimator struct{}
+
+func (zeroEstimator) EstimateSize(checker.AstNode) *checker.SizeEstimate { return nil }
+func (zeroEstimator) EstimateCallCost(string, string, *checker.AstNode, []checker.AstNode) *checker.CallEstimate {
+	return nil
+}
+
+// Run 对每个 ast 分别做 benchmark：预热、GC、记录 MemStats，然后循环求值至少
+// Duration 时长并记录逐次延迟用于百分位统计，同时结合 env.EstimateCost 的静态
+// 代价与 cel.OptTrackCost 得到的实际代价。
+func Run(env *cel.Env, asts []*cel.Ast, expressions []string, objects []map[string]interface{}, params map[string]interface{}, opts Options) (*Report, error) {
+	if opts.Duration <= 0 {
+		opts.Duration = time.Second
+	}
+
+	stopProfiling, err := startCPUProfile(opts.CPUProfile)
+	if err != nil {
+		return nil, err
+	}
+	defer stopProfiling()
+
+	report := &Report{}
+	overallStart := time.Now()
+
+	for i, ast := range asts {
+		progOpts := []cel.ProgramOption{cel.EvalOptions(cel.OptTrackCost)}
+		var maxCostLimit *uint64
+		if i < len(opts.MaxCosts) {
+			maxCostLimit = opts.MaxCosts[i]
+		}
+		if maxCostLimit != nil {
+			progOpts = append(progOpts, cel.CostLimit(*maxCostLimit))
+		}
+
+		prg, err := env.Program(ast, progOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create program for expression %d: %w", i+1, err)
+		}
+
+		minCost, maxCost := EstimateStaticCost(env, ast)
+
+		for k := 0; k < 10; k++ {
+			for _, object := range objects {
+				_, _, _ = prg.Eval(map[string]interface{}{"object": object, "params": params})
+			}
+		}
+
+		runtime.GC()
+		var memBefore runtime.MemStats
+		runtime.ReadMemStats(&memBefore)
+
+		samples, actualCost, exceeded, workerErr := runParallel(prg, objects, params, opts.Duration, workerpool.Workers(opts.Workers, 0))
+		if workerErr != nil {
+			return nil, fmt.Errorf("error during benchmark for expression %d: %w", i+1, workerErr)
+		}
+
+		var memAfter runtime.MemStats
+		runtime.ReadMemStats(&memAfter)
+
+		sort.Slice(samples, func(a, b int) bool { return samples[a] < samples[b] })
+		runs := len(samples)
+
+		report.Stats = append(report.Stats, ExprStat{
+			Index:         i + 1,
+			Expression:    truncate(expressions[i], 80),
+			Runs:          runs,
+			Min:           percentile(samples, 0),
+			Median:        percentile(samples, 0.5),
+			P95:           percentile(samples, 0.95),
+			P99:           percentile(samples, 0.99),
+			Max:           percentile(samples, 1),
+			AllocsPerOp:   divFloor(memAfter.Mallocs-memBefore.Mallocs, uint64(runs)),
+			BytesPerOp:    divFloor(memAfter.TotalAlloc-memBefore.TotalAlloc, uint64(runs)),
+			StaticMinCost: minCost,
+			StaticMaxCost: maxCost,
+			ActualCost:    actualCost,
+			Exceeded:      exceeded,
+		})
+	}
+
+	report.TotalDuration = time.Since(overallStart)
+
+	if err := writeMemProfile(opts.MemProfile); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// runParallel 用 workers 个 goroutine 并发地反复对 objects 求值，直到累计运行
+// 时长达到 duration，或某个 worker 触发了 cost 预算/其他错误（此时所有 worker
+// 尽快停下）。cel.Program 本身可安全并发求值，这里把各个 worker 的延迟样本直接
+// 拼接起来；不同 object 的实际 cost 可能天差地别（例如随 list 长度变化），
+// 因此上报的是所有 worker、所有 object 里观察到的最大实际 cost，而不是随机取
+// 某个 goroutine 恰好最后一个写入的样本——后者在并发下不可复现，违背了把
+// actual_cost 用于 CI 回归追踪的初衷。
+func runParallel(prg cel.Program, objects []map[string]interface{}, params map[string]interface{}, duration time.Duration, workers int) ([]time.Duration, uint64, bool, error) {
+	if len(objects) == 0 {
+		return nil, 0, false, nil
+	}
+
+	var stop int32
+	var exceeded int32
+	var errVal atomic.Value
+	var actualCost uint64
+	var mu sync.Mutex
+	var allSamples []time.Duration
+
+	actPool := workerpool.NewActivationPool()
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	start := time.Now()
+	for w := 0; w < workers; w++ {
+		go func(offset int) {
+			defer wg.Done()
+			var local []time.Duration
+			var localCost uint64
+			for idx := offset; atomic.LoadInt32(&stop) == 0 && time.Since(start) < duration; idx++ {
+				object := objects[idx%len(objects)]
+				vars := actPool.Get()
+				vars["object"] = object
+				vars["params"] = params
+				evalStart := time.Now()
+				_, det, err := prg.Eval(vars)
+				local = append(local, time.Since(evalStart))
+				actPool.Put(vars)
+				if det != nil {
+					if c := det.ActualCost(); c != nil && *c > localCost {
+						localCost = *c
+					}
+				}
+				if err != nil {
+					if isCostExceededErr(err) {
+						atomic.StoreInt32(&exceeded, 1)
+					} else {
+						errVal.Store(err)
+					}
+					atomic.StoreInt32(&stop, 1)
+					break
+				}
+			}
+			mu.Lock()
+			allSamples = append(allSamples, local...)
+			if localCost > actualCost {
+				actualCost = localCost
+			}
+			mu.Unlock()
+		}(w)
+	}
+	wg.Wait()
+
+	if v := errVal.Load(); v != nil {
+		return nil, 0, false, v.(error)
+	}
+	return allSamples, actualCost, atomic.LoadInt32(&exceeded) == 1, nil
+}
+
+// EstimateStaticCost 返回表达式的静态最小/最大 CEL cost，离线场景下拿不到真实
+// 的字段大小信息，未知大小一律按 cel-go 的默认假设处理。
+func EstimateStaticCost(env *cel.Env, ast *cel.Ast) (min, max uint64) {
+	est, err := env.EstimateCost(ast, zeroEstimator{})
+	if err != nil {
+		return 0, 0
+	}
+	return est.Min, est.Max
+}
+
+// isCostExceededErr 判断一次求值失败是否是因为触发了 cel.CostLimit。
+func isCostExceededErr(err error) bool {
+	return err != nil && strings.Contains(strings.ToLower(err.Error()), "cost limit exceeded")
+}
+
+func percentile(samples []time.Duration, p float64) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	idx := int(p * float64(len(samples)-1))
+	return samples[idx]
+}
+
+func divFloor(total, count uint64) uint64 {
+	if count == 0 {
+		return 0
+	}
+	return total / count
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
+func startCPUProfile(path string) (func(), error) {
+	if path == "" {
+		return func() {}, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cpu profile: %w", err)
+	}
+	if err := pprof.StartCPUProfile(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to start cpu profile: %w", err)
+	}
+	return func() {
+		pprof.StopCPUProfile()
+		f.Close()
+	}, nil
+}
+
+func writeMemProfile(path string) error {
+	if path == "" {
+		return nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create mem profile: %w", err)
+	}
+	defer f.Close()
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		return fmt.Errorf("failed to write mem profile: %w", err)
+	}
+	return nil
+}
+
+// WriteJSON 把报告编码为缩进的 JSON。
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteCSV 把报告编码为 CSV，每个表达式一行。
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	defer cw.Flush()
+
+	header := []string{
+		"index", "expression", "runs", "min_ns", "median_ns", "p95_ns", "p99_ns", "max_ns",
+		"allocs_per_op", "bytes_per_op", "static_min_cost", "static_max_cost", "actual_cost",
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, s := range r.Stats {
+		row := []string{
+			strconv.Itoa(s.Index),
+			s.Expression,
+			strconv.Itoa(s.Runs),
+			strconv.FormatInt(int64(s.Min), 10),
+			strconv.FormatInt(int64(s.Median), 10),
+			strconv.FormatInt(int64(s.P95), 10),
+			strconv.FormatInt(int64(s.P99), 10),
+			strconv.FormatInt(int64(s.Max), 10),
+			strconv.FormatUint(s.AllocsPerOp, 10),
+			strconv.FormatUint(s.BytesPerOp, 10),
+			strconv.FormatUint(s.StaticMinCost, 10),
+			strconv.FormatUint(s.StaticMaxCost, 10),
+			strconv.FormatUint(s.ActualCost, 10),
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteText 打印人类可读的摘要，沿用此前纯文本 benchmark 输出的格式。
+func (r *Report) WriteText(w io.Writer) {
+	fmt.Fprintln(w, "\n======= BENCHMARK =======")
+	for _, s := range r.Stats {
+		fmt.Fprintf(w, "\n--- Expression %d ---\n", s.Index)
+		fmt.Fprintf(w, "Content: %s\n", s.Expression)
+		fmt.Fprintf(w, "Runs: %d\n", s.Runs)
+		fmt.Fprintf(w, "Min/Median/P95/P99/Max: %v / %v / %v / %v / %v\n", s.Min, s.Median, s.P95, s.P99, s.Max)
+		fmt.Fprintf(w, "Allocs/op: %d, Bytes/op: %d\n", s.AllocsPerOp, s.BytesPerOp)
+		fmt.Fprintf(w, "Static cost: min=%d max=%d, actual cost: %d\n", s.StaticMinCost, s.StaticMaxCost, s.ActualCost)
+		if s.Exceeded {
+			fmt.Fprintf(w, "Cost budget exceeded, stopped early\n")
+		}
+	}
+	fmt.Fprintf(w, "\n======= SUMMARY =======\n")
+	fmt.Fprintf(w, "Total duration: %v\n", r.TotalDuration)
+}
@@ -0,0 +1,170 @@
+package bench
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+)
+
+func TestPercentile(t *testing.T) {
+	samples := []time.Duration{10, 20, 30, 40, 50}
+
+	cases := []struct {
+		name string
+		p    float64
+		want time.Duration
+	}{
+		{name: "min", p: 0, want: 10},
+		{name: "median", p: 0.5, want: 30},
+		{name: "max", p: 1, want: 50},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := percentile(samples, tc.p); got != tc.want {
+				t.Errorf("percentile(samples, %v) = %v, want %v", tc.p, got, tc.want)
+			}
+		})
+	}
+
+	if got := percentile(nil, 0.5); got != 0 {
+		t.Errorf("percentile(nil, 0.5) = %v, want 0", got)
+	}
+}
+
+func TestDivFloor(t *testing.T) {
+	cases := []struct {
+		name  string
+		total uint64
+		count uint64
+		want  uint64
+	}{
+		{name: "exact division", total: 10, count: 2, want: 5},
+		{name: "truncates like integer division", total: 7, count: 2, want: 3},
+		{name: "zero count avoids divide by zero", total: 7, count: 0, want: 0},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := divFloor(tc.total, tc.count); got != tc.want {
+				t.Errorf("divFloor(%d, %d) = %d, want %d", tc.total, tc.count, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		n    int
+		want string
+	}{
+		{name: "shorter than limit is untouched", in: "abc", n: 5, want: "abc"},
+		{name: "exactly at limit is untouched", in: "abcde", n: 5, want: "abcde"},
+		{name: "longer than limit gets an ellipsis", in: "abcdefgh", n: 5, want: "abcde..."},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := truncate(tc.in, tc.n); got != tc.want {
+				t.Errorf("truncate(%q, %d) = %q, want %q", tc.in, tc.n, got, tc.want)
+			}
+		})
+	}
+}
+
+// newBenchEnv builds a minimal CEL environment with the same object/params
+// declarations the rest of the tool uses.
+func newBenchEnv(t *testing.T) *cel.Env {
+	t.Helper()
+	env, err := cel.NewEnv(cel.Declarations(
+		decls.NewVar("object", decls.NewMapType(decls.String, decls.Dyn)),
+		decls.NewVar("params", decls.NewMapType(decls.String, decls.Dyn)),
+	))
+	if err != nil {
+		t.Fatalf("cel.NewEnv() error = %v", err)
+	}
+	return env
+}
+
+func compileForBench(t *testing.T, env *cel.Env, expr string) cel.Program {
+	t.Helper()
+	ast, issues := env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		t.Fatalf("Compile(%q) error = %v", expr, issues.Err())
+	}
+	prg, err := env.Program(ast, cel.EvalOptions(cel.OptTrackCost))
+	if err != nil {
+		t.Fatalf("Program() error = %v", err)
+	}
+	return prg
+}
+
+func TestRunParallelEmptyObjects(t *testing.T) {
+	env := newBenchEnv(t)
+	prg := compileForBench(t, env, "true")
+
+	samples, actualCost, exceeded, err := runParallel(prg, nil, nil, time.Second, 2)
+	if err != nil {
+		t.Fatalf("runParallel() error = %v", err)
+	}
+	if samples != nil || actualCost != 0 || exceeded {
+		t.Errorf("runParallel(no objects) = (%v, %d, %v), want (nil, 0, false)", samples, actualCost, exceeded)
+	}
+}
+
+// TestRunParallelReportsMaxActualCost makes sure the reported actual cost is
+// the maximum observed across every object and worker, not whichever
+// goroutine happened to write last.
+func TestRunParallelReportsMaxActualCost(t *testing.T) {
+	env := newBenchEnv(t)
+	prg := compileForBench(t, env, "object.items.all(i, i > 0)")
+
+	objects := []map[string]interface{}{
+		{"items": []interface{}{int64(1)}},
+		{"items": []interface{}{int64(1), int64(1), int64(1), int64(1), int64(1)}},
+	}
+
+	_, actualCost, exceeded, err := runParallel(prg, objects, nil, 30*time.Millisecond, 4)
+	if err != nil {
+		t.Fatalf("runParallel() error = %v", err)
+	}
+	if exceeded {
+		t.Fatal("runParallel() exceeded = true, want false")
+	}
+
+	// The cost of evaluating the five-element list must dominate the
+	// reported actual cost; a last-writer-wins implementation would
+	// sometimes report the one-element list's smaller cost instead.
+	_, smallCost, _, err := runParallel(prg, objects[:1], nil, 10*time.Millisecond, 1)
+	if err != nil {
+		t.Fatalf("runParallel() error = %v", err)
+	}
+	if actualCost <= smallCost {
+		t.Errorf("actualCost = %d, want it to exceed the one-element list's cost %d", actualCost, smallCost)
+	}
+}
+
+// TestRunParallelPropagatesError makes sure a runtime evaluation error on any
+// object is surfaced to the caller instead of being silently swallowed.
+func TestRunParallelPropagatesError(t *testing.T) {
+	env := newBenchEnv(t)
+	prg := compileForBench(t, env, "1 / object.divisor")
+
+	objects := []map[string]interface{}{
+		{"divisor": int64(1)},
+		{"divisor": int64(0)},
+	}
+
+	_, _, _, err := runParallel(prg, objects, nil, 200*time.Millisecond, 2)
+	if err == nil {
+		t.Fatal("runParallel() error = nil, want a division-by-zero error")
+	}
+	if !strings.Contains(err.Error(), "division by zero") {
+		t.Errorf("runParallel() error = %v, want it to mention division by zero", err)
+	}
+}
@@ -0,0 +1,156 @@
+// Package budget 给单次表达式求值附加 cost 预算与超时控制：解析表达式文件里
+// 以 `# max-cost:`/`# timeout:` 形式写在表达式前面的逐项覆盖，再用 cel.CostLimit
+// 与 context 超时包住 program.ContextEval。
+package budget
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/cel-go/cel"
+
+	"github.com/Hlinbit/cel-validation/bench"
+)
+
+// Expr 是表达式文件中一个 `---` 分隔块解析出的结果：去掉前导指令注释后的正文，
+// 以及该表达式专属的 cost/timeout 覆盖（未声明时为 nil/0，调用方应回退到全局
+// 的 --max-cost/--timeout）。
+type Expr struct {
+	Text    string
+	MaxCost *uint64
+	Timeout time.Duration
+}
+
+// ParseExpression 从一个表达式块中剥离形如
+//
+//	# max-cost: 100
+//	# timeout: 200ms
+//	object.replicas <= params.limit
+//
+// 这样的前导指令注释，返回剩余的表达式正文与解析出的覆盖项。无法识别的指令行
+// 会被当成普通注释保留在正文中交给 CEL 编译器报错，而不是静默丢弃。
+func ParseExpression(block string) Expr {
+	lines := strings.Split(block, "\n")
+	var e Expr
+
+	i := 0
+	for ; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+		directive := strings.TrimSpace(strings.TrimPrefix(line, "#"))
+
+		if v, ok := cutPrefix(directive, "max-cost:"); ok {
+			if n, err := strconv.ParseUint(strings.TrimSpace(v), 10, 64); err == nil {
+				e.MaxCost = &n
+				continue
+			}
+		}
+		if v, ok := cutPrefix(directive, "timeout:"); ok {
+			if d, err := time.ParseDuration(strings.TrimSpace(v)); err == nil {
+				e.Timeout = d
+				continue
+			}
+		}
+		break
+	}
+
+	e.Text = strings.TrimSpace(strings.Join(lines[i:], "\n"))
+	return e
+}
+
+func cutPrefix(s, prefix string) (string, bool) {
+	if !strings.HasPrefix(s, prefix) {
+		return "", false
+	}
+	return s[len(prefix):], true
+}
+
+// Result 是一次带 cost/timeout 控制的求值结果。
+type Result struct {
+	StaticMinCost uint64
+	StaticMaxCost uint64
+	ActualCost    *uint64
+	Out           interface{}
+	Err           error
+	Exceeded      bool // Err 是否由触发 cel.CostLimit 造成
+}
+
+// Compiled 是针对某个 ast 预先做好的 program planning 与静态 cost 估算：两者都
+// 只取决于 ast 与 maxCost，跟具体求值时传入的 object/params 无关，因此应该对
+// 同一个 (ast, maxCost) 只 Prepare 一次，再对所有 object 反复调用 Eval 复用。
+type Compiled struct {
+	program       cel.Program
+	staticMinCost uint64
+	staticMaxCost uint64
+}
+
+// Prepare 在应用 maxCost（可为 nil，表示不设限）的前提下为 ast 规划一次
+// cel.Program，并记录静态 min/max cost 估算。调用方（尤其是要在大量 object 上
+// 求值同一个表达式的场景）应该复用返回的 *Compiled，而不是对每个 object 都
+// 重新 Prepare 一遍。
+func Prepare(env *cel.Env, ast *cel.Ast, maxCost *uint64) (*Compiled, error) {
+	minCost, maxCostEstimate := bench.EstimateStaticCost(env, ast)
+
+	progOpts := []cel.ProgramOption{cel.EvalOptions(cel.OptTrackCost)}
+	if maxCost != nil {
+		progOpts = append(progOpts, cel.CostLimit(*maxCost))
+	}
+
+	prg, err := env.Program(ast, progOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create program: %w", err)
+	}
+
+	return &Compiled{program: prg, staticMinCost: minCost, staticMaxCost: maxCostEstimate}, nil
+}
+
+// Eval 在应用 timeout（零值表示不设超时）的前提下，用 c 已经规划好的 program
+// 对 vars 求值；cel.Program 可安全并发调用，同一个 *Compiled 可以在多个
+// goroutine 间共享。
+func (c *Compiled) Eval(vars map[string]interface{}, timeout time.Duration) *Result {
+	result := &Result{StaticMinCost: c.staticMinCost, StaticMaxCost: c.staticMaxCost}
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	out, det, err := c.program.ContextEval(ctx, vars)
+	if det != nil {
+		if v := det.ActualCost(); v != nil {
+			result.ActualCost = v
+		}
+	}
+	if err != nil {
+		result.Err = err
+		result.Exceeded = isCostExceededErr(err)
+		return result
+	}
+	result.Out = out.Value()
+	return result
+}
+
+// Evaluate 是 Prepare 后立即 Eval 一次的便捷包装，等价于只在一个 object 上求值
+// 该表达式。在 N 个 object 上重复求同一个表达式时应该改用 Prepare 一次、
+// Eval 多次，避免每个 object 都重新做 program planning 与静态 cost 估算。
+func Evaluate(env *cel.Env, ast *cel.Ast, vars map[string]interface{}, maxCost *uint64, timeout time.Duration) (*Result, error) {
+	compiled, err := Prepare(env, ast, maxCost)
+	if err != nil {
+		return nil, err
+	}
+	return compiled.Eval(vars, timeout), nil
+}
+
+func isCostExceededErr(err error) bool {
+	return err != nil && strings.Contains(strings.ToLower(err.Error()), "cost limit exceeded")
+}
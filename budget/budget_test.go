@@ -0,0 +1,72 @@
+package budget
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseExpressionHeaders(t *testing.T) {
+	cases := []struct {
+		name        string
+		block       string
+		wantText    string
+		wantMaxCost *uint64
+		wantTimeout time.Duration
+	}{
+		{
+			name:     "no headers",
+			block:    "object.replicas <= params.limit",
+			wantText: "object.replicas <= params.limit",
+		},
+		{
+			name:        "max-cost header",
+			block:       "# max-cost: 100\nobject.replicas <= params.limit",
+			wantText:    "object.replicas <= params.limit",
+			wantMaxCost: uint64Ptr(100),
+		},
+		{
+			name:        "max-cost: 0 is parsed as a literal zero override",
+			block:       "# max-cost: 0\nobject.replicas <= params.limit",
+			wantText:    "object.replicas <= params.limit",
+			wantMaxCost: uint64Ptr(0),
+		},
+		{
+			name:        "timeout header",
+			block:       "# timeout: 200ms\nobject.replicas <= params.limit",
+			wantText:    "object.replicas <= params.limit",
+			wantTimeout: 200 * time.Millisecond,
+		},
+		{
+			name:        "both headers in any order",
+			block:       "# timeout: 1s\n# max-cost: 5\nobject.replicas <= params.limit",
+			wantText:    "object.replicas <= params.limit",
+			wantMaxCost: uint64Ptr(5),
+			wantTimeout: time.Second,
+		},
+		{
+			name:     "unrecognized directive is kept as part of the body",
+			block:    "# not-a-real-directive: x\nobject.replicas <= params.limit",
+			wantText: "# not-a-real-directive: x\nobject.replicas <= params.limit",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := ParseExpression(tc.block)
+			if e.Text != tc.wantText {
+				t.Errorf("Text = %q, want %q", e.Text, tc.wantText)
+			}
+			if (e.MaxCost == nil) != (tc.wantMaxCost == nil) {
+				t.Fatalf("MaxCost = %v, want %v", e.MaxCost, tc.wantMaxCost)
+			}
+			if e.MaxCost != nil && *e.MaxCost != *tc.wantMaxCost {
+				t.Errorf("MaxCost = %d, want %d", *e.MaxCost, *tc.wantMaxCost)
+			}
+			if e.Timeout != tc.wantTimeout {
+				t.Errorf("Timeout = %v, want %v", e.Timeout, tc.wantTimeout)
+			}
+		})
+	}
+}
+
+func uint64Ptr(v uint64) *uint64 { return &v }
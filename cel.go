@@ -1,37 +1,45 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
-	"io"
 	"log"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/cel-go/cel"
 	"github.com/google/cel-go/checker/decls"
 	"github.com/google/cel-go/ext"
 	"gopkg.in/yaml.v3"
+
+	"github.com/Hlinbit/cel-validation/bench"
+	"github.com/Hlinbit/cel-validation/budget"
+	"github.com/Hlinbit/cel-validation/envconfig"
+	"github.com/Hlinbit/cel-validation/golden"
+	"github.com/Hlinbit/cel-validation/policy"
+	"github.com/Hlinbit/cel-validation/sources"
+	"github.com/Hlinbit/cel-validation/workerpool"
 )
 
-// parseYAMLDocuments 解析包含多个YAML文档的文件（用---分隔）
-func parseYAMLDocuments(data []byte) ([]map[string]interface{}, error) {
-	decoder := yaml.NewDecoder(strings.NewReader(string(data)))
-	var documents []map[string]interface{}
+// stringListFlag 让 --lib 既能重复传递（--lib=a --lib=b），也能用逗号一次性
+// 传多个库名（--lib=a,b）。
+type stringListFlag []string
 
-	for {
-		var doc map[string]interface{}
-		err := decoder.Decode(&doc)
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil, err
+func (s *stringListFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringListFlag) Set(value string) error {
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			*s = append(*s, part)
 		}
-		documents = append(documents, doc)
 	}
-
-	return documents, nil
+	return nil
 }
 
 // parseSingleYAMLDocument 解析单个YAML文档，忽略后续内容
@@ -50,146 +58,461 @@ func loadFile(filename string) ([]byte, error) {
 	return os.ReadFile(filename)
 }
 
-// compileExpressions 编译表达式并返回程序列表
-func compileExpressions(env *cel.Env, expressions []string) ([]cel.Program, error) {
-	var programs []cel.Program
-	for _, expr := range expressions {
+// nonEmptyExpressions 过滤掉空表达式块，返回正文与对应的预算覆盖，下标一一对应。
+func nonEmptyExpressions(expressions []string, overrides []budget.Expr) ([]string, []budget.Expr) {
+	var kept []string
+	var keptOverrides []budget.Expr
+	for i, expr := range expressions {
 		if expr == "" {
 			continue
 		}
+		kept = append(kept, expr)
+		keptOverrides = append(keptOverrides, overrides[i])
+	}
+	return kept, keptOverrides
+}
 
+// compileASTs 编译一组非空表达式并返回对应的 AST 列表。
+func compileASTs(env *cel.Env, expressions []string) ([]*cel.Ast, error) {
+	var asts []*cel.Ast
+	for _, expr := range expressions {
 		ast, issues := env.Compile(expr)
 		if issues != nil && issues.Err() != nil {
 			return nil, fmt.Errorf("compilation failed: %v", issues.Err())
 		}
+		asts = append(asts, ast)
+	}
+	return asts, nil
+}
+
+// effectiveMaxCost 返回表达式专属的 --max-cost 覆盖，未声明时回退到全局默认值。
+// 0 在 `# max-cost:` 头部与全局 --max-cost 标志里含义一致，都表示"不设限"，
+// 而不是字面意义上的 CostLimit(0)（那会让任何有实际开销的求值都立刻失败）。
+func effectiveMaxCost(override budget.Expr, globalMaxCost uint64) *uint64 {
+	if override.MaxCost != nil {
+		if *override.MaxCost == 0 {
+			return nil
+		}
+		return override.MaxCost
+	}
+	if globalMaxCost > 0 {
+		v := globalMaxCost
+		return &v
+	}
+	return nil
+}
+
+// effectiveTimeout 返回表达式专属的 --timeout 覆盖，未声明时回退到全局默认值。
+func effectiveTimeout(override budget.Expr, globalTimeout time.Duration) time.Duration {
+	if override.Timeout > 0 {
+		return override.Timeout
+	}
+	return globalTimeout
+}
+
+// buildExtraEnvOptions 把 env.yaml/--lib 选中的扩展库、自定义变量声明与宏限制
+// 汇总成一组 cel.EnvOption，供普通模式与 policy 模式共用。
+func buildExtraEnvOptions(cfg *envconfig.Config, cliLibs []string) ([]cel.EnvOption, error) {
+	var opts []cel.EnvOption
+
+	libOpts, err := envconfig.ResolveLibraries(cfg.MergeLibraries(cliLibs))
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, libOpts...)
 
-		program, err := env.Program(ast)
+	if len(cfg.Variables) > 0 {
+		varsOpt, err := envconfig.DeclareVariables(cfg.Variables)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create program: %v", err)
+			return nil, fmt.Errorf("failed to declare custom variables: %w", err)
 		}
+		opts = append(opts, varsOpt)
+	}
 
-		programs = append(programs, program)
+	macroOpts, err := envconfig.SelectMacros(cfg.Macros)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select macros: %w", err)
 	}
-	return programs, nil
+	opts = append(opts, macroOpts...)
+
+	return opts, nil
 }
 
-// runBenchmark 运行基准测试，按expression分别输出结果
-func runBenchmark(objects []map[string]interface{}, programs []cel.Program, params map[string]interface{}, expressions []string) {
-	fmt.Println("\n======= BENCHMARK =======")
-
-	// 预热运行
-	fmt.Println("Warming up...")
-	for i := 0; i < 10; i++ {
-		for _, object := range objects {
-			for _, program := range programs {
-				_, _, _ = program.Eval(map[string]interface{}{
-					"object": object,
-					"params": params,
-				})
+// runPolicy 以 ValidatingAdmissionPolicy 模式运行：policyFile 是一份 VAP YAML
+// 清单，objectFile 提供待校验的 object（支持多文档），paramsFile 提供绑定给
+// spec.paramKind 的参数资源。
+func runPolicy(objectFile, policyFile, paramsFile string, cfg *envconfig.Config, cliLibs []string) {
+	policyData, err := loadFile(policyFile)
+	if err != nil {
+		log.Fatal("Failed to read policy file: ", err)
+	}
+	bundle, err := policy.ParseBundle(policyData)
+	if err != nil {
+		log.Fatal("Failed to parse policy bundle: ", err)
+	}
+
+	objects, err := sources.Load(objectFile)
+	if err != nil {
+		log.Fatal("Failed to load object file: ", err)
+	}
+
+	paramsData, err := loadFile(paramsFile)
+	if err != nil {
+		log.Fatal("Failed to read params file: ", err)
+	}
+	params, err := parseSingleYAMLDocument(paramsData)
+	if err != nil {
+		log.Fatal("Failed to parse params YAML: ", err)
+	}
+
+	extraOpts, err := buildExtraEnvOptions(cfg, cliLibs)
+	if err != nil {
+		log.Fatal("Failed to configure CEL environment: ", err)
+	}
+	if len(extraOpts) == 0 {
+		extraOpts = []cel.EnvOption{ext.Strings()}
+	}
+
+	env, err := policy.BuildEnv(bundle, extraOpts...)
+	if err != nil {
+		log.Fatal("Failed to build policy environment: ", err)
+	}
+
+	prepared, err := policy.Prepare(env, bundle)
+	if err != nil {
+		log.Fatal("Failed to compile policy bundle: ", err)
+	}
+
+	for objIdx, object := range objects {
+		fmt.Printf("\n======= Object %d =======\n", objIdx+1)
+
+		result, err := prepared.Eval(object, nil, params)
+		if err != nil {
+			fmt.Printf("Policy evaluation failed: %v\n", err)
+			continue
+		}
+
+		if !result.Matched {
+			if result.MatchConditionErr != nil {
+				fmt.Printf("matchConditions error: %v\n", result.MatchConditionErr)
+			} else {
+				fmt.Println("matchConditions not satisfied, validations skipped")
 			}
+			continue
 		}
-	}
 
-	// 为每个expression创建单独的统计信息
-	type exprStats struct {
-		evalCount int
-		duration  time.Duration
+		for vIdx, outcome := range result.Outcomes {
+			fmt.Printf("\n--- Validation %d ---\n%s\n", vIdx+1, outcome.Validation.Expression)
+			if outcome.Error != nil {
+				fmt.Printf("Evaluation failed: %v\n", outcome.Error)
+				continue
+			}
+			fmt.Printf("Passed: %v\n", outcome.Passed)
+			if !outcome.Passed {
+				if outcome.Message != "" {
+					fmt.Printf("Message: %s\n", outcome.Message)
+				}
+				if outcome.Reason != "" {
+					fmt.Printf("Reason: %s\n", outcome.Reason)
+				}
+			}
+		}
 	}
+}
 
-	stats := make([]exprStats, len(programs))
+// streamResult 是 --stream 模式下每行输出的 JSON 结构。
+type streamResult struct {
+	ObjectIndex int         `json:"object_index"`
+	Expression  string      `json:"expression"`
+	Result      interface{} `json:"result,omitempty"`
+	Error       string      `json:"error,omitempty"`
+}
 
-	// 正式测试
-	fmt.Println("Running benchmark...")
-	startTime := time.Now()
+// streamJob 是读取 goroutine 喂给 worker 池的一个待求值 object。
+type streamJob struct {
+	index  int
+	object map[string]interface{}
+}
 
-	// 运行至少1秒钟以获得更准确的结果
-	for i, program := range programs {
-		exprStart := time.Now()
-		for k := 0; k < 1024*1024; k++ {
-			for _, object := range objects {
-				_, _, err := program.Eval(map[string]interface{}{
-					"object": object,
-					"params": params,
-				})
+// streamOutput 是某个 worker 对一个 object 求值完的全部表达式结果，等待按
+// index 重新排好序再打印。
+type streamOutput struct {
+	index int
+	lines []streamResult
+}
 
-				if err != nil {
-					fmt.Printf("Error during benchmark for expression %d: %v\n", i+1, err)
-					return
+// runStream 增量地从 objectFile 读取 object（支持管道里跑不下内存的数据集），
+// 用 workers 个 goroutine 并发对每个读到的 object 求值，再由一个打印 goroutine
+// 按 object 到达顺序重新拼好后逐行输出 JSON，可直接接到下游管道里。
+func runStream(objectFile string, env *cel.Env, expressions []string, overrides []budget.Expr, globalMaxCost uint64, globalTimeout time.Duration, params map[string]interface{}, workers int) {
+	// 每个表达式只编译、规划一次 program，所有 worker goroutine 在全部 object
+	// 上共用同一个 *budget.Compiled（cel.Program 可安全并发求值），而不是在每个
+	// object 上都重新做一遍 program planning 与静态 cost 估算。
+	compiled := make([]*budget.Compiled, len(expressions))
+	for i, expr := range expressions {
+		if expr == "" {
+			continue
+		}
+		ast, issues := env.Compile(expr)
+		if issues != nil && issues.Err() != nil {
+			log.Fatalf("Failed to compile expression %d: %v", i+1, issues.Err())
+		}
+		c, err := budget.Prepare(env, ast, effectiveMaxCost(overrides[i], globalMaxCost))
+		if err != nil {
+			log.Fatalf("Failed to prepare expression %d: %v", i+1, err)
+		}
+		compiled[i] = c
+	}
+
+	workers = workerpool.Workers(workers, 0)
+	actPool := workerpool.NewActivationPool()
+
+	jobs := make(chan streamJob, workers)
+	outputs := make(chan streamOutput, workers)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				var lines []streamResult
+				for i, expr := range expressions {
+					if expr == "" {
+						continue
+					}
+
+					vars := actPool.Get()
+					vars["object"] = job.object
+					vars["params"] = params
+
+					line := streamResult{ObjectIndex: job.index, Expression: expr}
+					result := compiled[i].Eval(vars, effectiveTimeout(overrides[i], globalTimeout))
+					if result.Err != nil {
+						line.Error = result.Err.Error()
+					} else {
+						line.Result = result.Out
+					}
+					actPool.Put(vars)
+
+					lines = append(lines, line)
 				}
+				outputs <- streamOutput{index: job.index, lines: lines}
 			}
+		}()
+	}
+
+	readErrCh := make(chan error, 1)
+	go func() {
+		readErrCh <- sources.Stream(objectFile, func(index int, object map[string]interface{}) error {
+			jobs <- streamJob{index: index, object: object}
+			return nil
+		})
+		close(jobs)
+	}()
+
+	go func() {
+		wg.Wait()
+		close(outputs)
+	}()
+
+	// 打印 goroutine：worker 完成顺序不确定，这里按 index 缓存乱序到达的结果，
+	// 只在能凑齐"下一个该输出的 index"时才真正写出去，从而保持与输入一致的顺序。
+	encoder := json.NewEncoder(os.Stdout)
+	pending := make(map[int][]streamResult)
+	next := 0
+	for out := range outputs {
+		pending[out.index] = out.lines
+		for {
+			lines, ok := pending[next]
+			if !ok {
+				break
+			}
+			for _, line := range lines {
+				if err := encoder.Encode(line); err != nil {
+					log.Fatal("Failed to write stream result: ", err)
+				}
+			}
+			delete(pending, next)
+			next++
 		}
-		stats[i].duration += time.Since(exprStart)
-		stats[i].evalCount = 1024 * 1024
 	}
 
-	totalDuration := time.Since(startTime)
+	if err := <-readErrCh; err != nil {
+		log.Fatal("Streaming evaluation failed: ", err)
+	}
+}
+
+// runAssert 对每个带 `# expect:`/`# expect-error:` 标注的表达式，断言它在全部
+// object 上的求值结果都符合预期；遇到不一致就打印 kr/pretty 风格的 diff 并在
+// 结束后以非零状态码退出。update 为真时不做任何断言，而是把观测到的结果格式化
+// 成 `# expect:`/`# expect-error:` 指令行写回 expressionFile，对应 Go 测试里
+// -update 刷新 golden 文件的惯例。
+func runAssert(expressionFile string, rawBlocks []string, objects []map[string]interface{}, env *cel.Env, expressions []string, overrides []budget.Expr, expectations []*golden.Expectation, globalMaxCost uint64, globalTimeout time.Duration, params map[string]interface{}, update bool) {
+	updatedBlocks := make([]string, len(rawBlocks))
+	copy(updatedBlocks, rawBlocks)
+
+	failures := 0
+	for i, expr := range expressions {
+		if expr == "" {
+			continue
+		}
+
+		ast, issues := env.Compile(expr)
+		if issues != nil && issues.Err() != nil {
+			fmt.Printf("Expression %d: compilation failed: %v\n", i+1, issues.Err())
+			failures++
+			continue
+		}
+
+		exp := expectations[i]
+		if exp == nil && !update {
+			continue
+		}
+
+		// program planning 与静态 cost 估算只取决于 ast/maxCost，对该表达式只
+		// 做一次，下面在全部 object 上复用，而不是每个 object 都重新 Prepare。
+		compiled, err := budget.Prepare(env, ast, effectiveMaxCost(overrides[i], globalMaxCost))
+		if err != nil {
+			fmt.Printf("Expression %d: failed to create program: %v\n", i+1, err)
+			failures++
+			continue
+		}
+
+		var lastOut interface{}
+		var lastErr error
+		diverged := false
+		mismatch := false
+		for objIdx, object := range objects {
+			vars := map[string]interface{}{"object": object, "params": params}
+			result := compiled.Eval(vars, effectiveTimeout(overrides[i], globalTimeout))
+
+			if objIdx == 0 {
+				lastOut, lastErr = result.Out, result.Err
+			} else if !sameObservation(lastOut, lastErr, result.Out, result.Err) {
+				diverged = true
+			}
 
-	// 输出每个expression的详细结果
-	for i, stat := range stats {
-		fmt.Printf("\n--- Expression %d ---\n", i+1)
-		if i < len(expressions) {
-			fmt.Printf("Content: %s\n", expressions[i][:min(50, len(expressions[i]))]+"...")
+			if exp != nil && !update && !exp.Matches(result.Out, result.Err) {
+				mismatch = true
+				fmt.Printf("Expression %d, object %d: assertion failed\n%s\n", i+1, objIdx+1, strings.Join(exp.Diff(result.Out, result.Err), "\n"))
+			}
 		}
-		fmt.Printf("Evaluations: %d\n", stat.evalCount)
-		fmt.Printf("Total time: %v\n", stat.duration)
-		if stat.evalCount > 0 {
-			fmt.Printf("Average time per evaluation: %v\n", stat.duration/time.Duration(stat.evalCount))
-			fmt.Printf("Evaluations per second: %.0f\n", float64(stat.evalCount)/totalDuration.Seconds())
+		if mismatch {
+			failures++
+		}
+
+		if update {
+			if diverged {
+				fmt.Printf("Expression %d: observed results differ across objects, recording the first object's result\n", i+1)
+			}
+			directive, err := golden.Directive(lastOut, lastErr)
+			if err != nil {
+				log.Fatalf("Failed to format observed result for expression %d: %v", i+1, err)
+			}
+			updatedBlocks[i] = rewriteExpectation(rawBlocks[i], directive)
 		}
 	}
 
-	// 输出总体统计
-	totalEvals := 0
-	for _, stat := range stats {
-		totalEvals += stat.evalCount
+	if update {
+		if err := os.WriteFile(expressionFile, []byte(strings.Join(updatedBlocks, "---")), 0644); err != nil {
+			log.Fatal("Failed to update expression file: ", err)
+		}
+		fmt.Println("Expression file updated.")
+		return
 	}
 
-	fmt.Printf("\n======= SUMMARY =======\n")
-	fmt.Printf("Total duration: %v\n", totalDuration)
-	fmt.Printf("Total evaluations: %d\n", totalEvals)
-	fmt.Printf("Overall evaluations per second: %.0f\n", float64(totalEvals)/totalDuration.Seconds())
+	if failures > 0 {
+		fmt.Printf("\n%d assertion(s) failed\n", failures)
+		os.Exit(1)
+	}
+	fmt.Println("All assertions passed.")
 }
 
-// min 返回两个整数中的较小值
-func min(a, b int) int {
-	if a < b {
-		return a
+// sameObservation 判断两次求值观测（结果或错误）是否一致，用于在 --update 时
+// 提示某条表达式在不同 object 上的观测结果并不统一。
+func sameObservation(out1 interface{}, err1 error, out2 interface{}, err2 error) bool {
+	if (err1 == nil) != (err2 == nil) {
+		return false
 	}
-	return b
+	if err1 != nil {
+		return err1.Error() == err2.Error()
+	}
+	return fmt.Sprintf("%v", out1) == fmt.Sprintf("%v", out2)
 }
-func main() {
-	// 检查是否启用benchmark模式
-	benchmarkMode := false
-	args := os.Args[1:]
-
-	// 过滤掉benchmark参数
-	var filteredArgs []string
-	for _, arg := range args {
-		if arg == "--benchmark" {
-			benchmarkMode = true
-		} else {
-			filteredArgs = append(filteredArgs, arg)
+
+// rewriteExpectation 把 block 里已有的 `# expect:`/`# expect-error:` 行替换成
+// directive，没有的话插到表达式块最前面；其余行（`# max-cost:`/`# timeout:`
+// 与表达式正文）原样保留。
+func rewriteExpectation(block, directive string) string {
+	lines := strings.Split(block, "\n")
+	var kept []string
+	inserted := false
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "# expect:") || strings.HasPrefix(trimmed, "# expect-error:") {
+			if !inserted {
+				kept = append(kept, directive)
+				inserted = true
+			}
+			continue
 		}
+		kept = append(kept, line)
+	}
+	if !inserted {
+		kept = append([]string{directive}, kept...)
+	}
+	return strings.Join(kept, "\n")
+}
+
+func main() {
+	var (
+		benchmarkMode = flag.Bool("benchmark", false, "run the expressions as a benchmark instead of evaluating once")
+		policyMode    = flag.Bool("policy", false, "treat the expression-file argument as a ValidatingAdmissionPolicy YAML bundle")
+		configFile    = flag.String("config", "", "path to an env.yaml declaring CEL libraries, custom variables and macros")
+		benchFormat   = flag.String("bench-format", "text", "benchmark report format: text, json, or csv")
+		benchOut      = flag.String("bench-out", "", "write the benchmark report to this file instead of stdout")
+		benchTime     = flag.Duration("benchtime", time.Second, "minimum duration to run each expression during --benchmark")
+		cpuProfile    = flag.String("cpuprofile", "", "write a CPU profile to this file during --benchmark")
+		memProfile    = flag.String("memprofile", "", "write a heap profile to this file after --benchmark")
+		maxCost       = flag.Uint64("max-cost", 0, "default CEL cost budget per expression (0 means unlimited); can be overridden per-expression via a `# max-cost: N` header")
+		timeout       = flag.Duration("timeout", 0, "default evaluation deadline per expression (0 means unlimited); can be overridden per-expression via a `# timeout: 200ms` header")
+		streamMode    = flag.Bool("stream", false, "read the object file incrementally and evaluate/emit each object as it arrives, one JSON result per line")
+		workers       = flag.Int("workers", 0, "number of goroutines used to evaluate (object, expression) pairs concurrently (0 means runtime.NumCPU())")
+		assertMode    = flag.Bool("assert", false, "check expressions against `# expect:`/`# expect-error:` annotations in the expression file and exit non-zero on divergence")
+		updateMode    = flag.Bool("update", false, "with --assert, rewrite the expression file's `# expect:`/`# expect-error:` annotations with the observed results instead of checking them")
+		libs          stringListFlag
+	)
+	flag.Var(&libs, "lib", "CEL extension library to register (repeatable, or comma-separated); see envconfig.LibraryNames")
+	flag.Parse()
+
+	cfg, err := envconfig.Load(*configFile)
+	if err != nil {
+		log.Fatal("Failed to load env config: ", err)
 	}
 
+	filteredArgs := flag.Args()
 	if len(filteredArgs) < 3 {
-		fmt.Println("Usage: program [--benchmark] <object-file> <expression-file> <params-file>")
+		fmt.Println("Usage: program [--benchmark] [--policy] [--config env.yaml] [--lib name] <object-file> <expression-file|policy-file> <params-file>")
 		os.Exit(1)
 	}
 
+	if *policyMode {
+		runPolicy(filteredArgs[0], filteredArgs[1], filteredArgs[2], cfg, libs)
+		return
+	}
+
 	objectFile := filteredArgs[0]
 	expressionFile := filteredArgs[1]
 	paramsFile := filteredArgs[2]
 
-	// 读取并解析object文件（支持多个文档）
-	objectData, err := loadFile(objectFile)
-	if err != nil {
-		log.Fatal("Failed to read object file: ", err)
-	}
-
-	objects, err := parseYAMLDocuments(objectData)
-	if err != nil {
-		log.Fatal("Failed to parse object YAML: ", err)
+	// --stream 模式下不会把整份 object 输入读进内存，改为在求值阶段增量读取
+	var objects []map[string]interface{}
+	if !*streamMode {
+		objects, err = sources.Load(objectFile)
+		if err != nil {
+			log.Fatal("Failed to load objects: ", err)
+		}
 	}
 
 	// 读取并解析expression文件（支持多个表达式）
@@ -198,10 +521,22 @@ func main() {
 		log.Fatal("Failed to read expression file: ", err)
 	}
 
-	expressions := strings.Split(strings.TrimSpace(string(expressionData)), "---")
-	// 清理表达式字符串
-	for i, expr := range expressions {
-		expressions[i] = strings.TrimSpace(expr)
+	// 拆分出每个表达式块，剥离 `# expect:`/`# expect-error:` 与
+	// `# max-cost:`/`# timeout:` 前导指令，得到正文、逐表达式的预算覆盖与
+	// （--assert/--update 用到的）预期结果
+	rawBlocks := strings.Split(strings.TrimSpace(string(expressionData)), "---")
+	expressions := make([]string, len(rawBlocks))
+	overrides := make([]budget.Expr, len(rawBlocks))
+	expectations := make([]*golden.Expectation, len(rawBlocks))
+	for i, block := range rawBlocks {
+		rest, exp, err := golden.Extract(block)
+		if err != nil {
+			log.Fatalf("Failed to parse expression block %d: %v", i+1, err)
+		}
+		parsed := budget.ParseExpression(rest)
+		expressions[i] = parsed.Text
+		overrides[i] = parsed
+		expectations[i] = exp
 	}
 
 	// 读取并解析params文件（只取第一个文档）
@@ -215,67 +550,194 @@ func main() {
 		log.Fatal("Failed to parse params YAML: ", err)
 	}
 
-	// 创建CEL环境
-	decls := cel.Declarations(
+	// 创建CEL环境：固定的 object/params 声明，加上 --lib/env.yaml 选中的扩展库、
+	// 自定义变量与宏限制
+	baseDecls := cel.Declarations(
 		decls.NewVar("object", decls.NewMapType(decls.String, decls.Dyn)),
 		decls.NewVar("params", decls.NewMapType(decls.String, decls.Dyn)),
 	)
 
-	env, err := cel.NewEnv(
-		decls,
-		ext.Strings(),
-	)
+	extraOpts, err := buildExtraEnvOptions(cfg, libs)
+	if err != nil {
+		log.Fatal("Failed to configure CEL environment: ", err)
+	}
+	if len(extraOpts) == 0 {
+		extraOpts = []cel.EnvOption{ext.Strings()}
+	}
+
+	env, err := cel.NewEnv(append([]cel.EnvOption{baseDecls}, extraOpts...)...)
 	if err != nil {
 		log.Fatal("Failed to create CEL environment: ", err)
 	}
 
-	// 如果是benchmark模式，只运行benchmark
-	if benchmarkMode {
-		programs, err := compileExpressions(env, expressions)
+	// --stream 模式：增量读取 object 输入，每读到一个就立刻对所有表达式求值并
+	// 打印一行 JSON，不等待整份输入读完
+	if *streamMode {
+		runStream(objectFile, env, expressions, overrides, *maxCost, *timeout, params, *workers)
+		return
+	}
+
+	// --assert 模式：校验（或用 --update 刷新）表达式文件里的 `# expect:`/
+	// `# expect-error:` 标注
+	if *assertMode {
+		runAssert(expressionFile, rawBlocks, objects, env, expressions, overrides, expectations, *maxCost, *timeout, params, *updateMode)
+		return
+	}
+
+	// 如果是benchmark模式，只运行benchmark并输出结构化报告
+	if *benchmarkMode {
+		kept, keptOverrides := nonEmptyExpressions(expressions, overrides)
+		asts, err := compileASTs(env, kept)
 		if err != nil {
 			log.Fatal("Failed to compile expressions for benchmark: ", err)
 		}
 
-		runBenchmark(objects, programs, params, expressions)
+		maxCosts := make([]*uint64, len(keptOverrides))
+		for i, o := range keptOverrides {
+			maxCosts[i] = effectiveMaxCost(o, *maxCost)
+		}
+
+		report, err := bench.Run(env, asts, kept, objects, params, bench.Options{
+			Duration:   *benchTime,
+			CPUProfile: *cpuProfile,
+			MemProfile: *memProfile,
+			MaxCosts:   maxCosts,
+			Workers:    *workers,
+		})
+		if err != nil {
+			log.Fatal("Benchmark failed: ", err)
+		}
+
+		out := os.Stdout
+		if *benchOut != "" {
+			f, err := os.Create(*benchOut)
+			if err != nil {
+				log.Fatal("Failed to create bench-out file: ", err)
+			}
+			defer f.Close()
+			out = f
+		}
+
+		switch *benchFormat {
+		case "json":
+			err = report.WriteJSON(out)
+		case "csv":
+			err = report.WriteCSV(out)
+		case "text":
+			report.WriteText(out)
+		default:
+			log.Fatalf("Unknown --bench-format %q (want text, json, or csv)", *benchFormat)
+		}
+		if err != nil {
+			log.Fatal("Failed to write benchmark report: ", err)
+		}
 		return
 	}
 
-	// 正常模式：对每个object和expression组合进行求值
-	for objIdx, object := range objects {
-		fmt.Printf("\n======= Object %d =======\n", objIdx+1)
+	// 正常模式：每个表达式只编译、规划一次 program，worker goroutine 在并发对
+	// 每个 object 求值所有表达式时直接复用，而不是对每个 object 都重新编译；
+	// 每个 object 渲染成一段文本，求值结束后再按 object 下标顺序打印，保证
+	// 输出顺序与单线程时一致。
+	prepared := prepareExpressions(env, expressions, overrides, *maxCost)
+	actPool := workerpool.NewActivationPool()
+	blocks := workerpool.Map(*workers, len(objects), func(objIdx int) interface{} {
+		return renderObjectResult(objects[objIdx], objIdx, expressions, prepared, overrides, *timeout, params, actPool)
+	})
+	for _, block := range blocks {
+		fmt.Print(block.(string))
+	}
+}
 
-		for exprIdx, expr := range expressions {
-			if expr == "" {
-				continue
-			}
+// preparedExpr 是某个表达式一次性编译并规划好 program 的结果，供所有 object
+// 共用；cel.Program 可安全并发求值，compileErr/prepErr 非空时说明该表达式在
+// 编译或 program planning 阶段就失败了，所有 object 都应该原样提示同一条
+// 错误，而不必重新尝试。
+type preparedExpr struct {
+	compiled   *budget.Compiled
+	compileErr string
+	libHint    string
+	prepErr    string
+}
 
-			fmt.Printf("\n--- Expression %d ---\n%s\n", exprIdx+1, expr)
+// prepareExpressions 把每个非空表达式编译并按各自的 cost 覆盖规划成
+// cel.Program，只做一次；renderObjectResult 在每个 object 上直接复用返回的
+// preparedExpr，不会重新编译或重新规划 program。
+func prepareExpressions(env *cel.Env, expressions []string, overrides []budget.Expr, globalMaxCost uint64) []preparedExpr {
+	prepared := make([]preparedExpr, len(expressions))
+	for i, expr := range expressions {
+		if expr == "" {
+			continue
+		}
+		ast, issues := env.Compile(expr)
+		if issues != nil && issues.Err() != nil {
+			prepared[i].compileErr = issues.Err().Error()
+			prepared[i].libHint = envconfig.SuggestLibraryForError(issues.Err().Error())
+			continue
+		}
+		compiled, err := budget.Prepare(env, ast, effectiveMaxCost(overrides[i], globalMaxCost))
+		if err != nil {
+			prepared[i].prepErr = err.Error()
+			continue
+		}
+		prepared[i].compiled = compiled
+	}
+	return prepared
+}
 
-			// 编译表达式
-			ast, issues := env.Compile(expr)
-			if issues != nil && issues.Err() != nil {
-				fmt.Printf("Compilation failed: %v\n", issues.Err())
-				continue
-			}
+// renderObjectResult 对单个 object 依次求值所有已预编译好的表达式，返回拼好的
+// 文本块；拆成独立函数便于在 workerpool.Map 里并发调用。
+func renderObjectResult(object map[string]interface{}, objIdx int, expressions []string, prepared []preparedExpr, overrides []budget.Expr, globalTimeout time.Duration, params map[string]interface{}, actPool *workerpool.ActivationPool) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\n======= Object %d =======\n", objIdx+1)
 
-			// 创建程序
-			program, err := env.Program(ast)
-			if err != nil {
-				fmt.Printf("Failed to create program: %v\n", err)
-				continue
+	for exprIdx, expr := range expressions {
+		if expr == "" {
+			continue
+		}
+
+		fmt.Fprintf(&sb, "\n--- Expression %d ---\n%s\n", exprIdx+1, expr)
+
+		p := prepared[exprIdx]
+		if p.compileErr != "" {
+			if p.libHint != "" {
+				fmt.Fprintf(&sb, "Compilation failed: %s (hint: enable --lib=%s)\n", p.compileErr, p.libHint)
+			} else {
+				fmt.Fprintf(&sb, "Compilation failed: %s\n", p.compileErr)
 			}
+			continue
+		}
+		if p.prepErr != "" {
+			fmt.Fprintf(&sb, "Failed to create program: %s\n", p.prepErr)
+			continue
+		}
 
-			// 求值
-			out, _, err := program.Eval(map[string]interface{}{
-				"object": object,
-				"params": params,
-			})
+		// 带 cost 预算与超时地求值：先打印静态 cost 估算，再实际执行
+		override := budget.Expr{}
+		if exprIdx < len(overrides) {
+			override = overrides[exprIdx]
+		}
+		vars := actPool.Get()
+		vars["object"] = object
+		vars["params"] = params
+		result := p.compiled.Eval(vars, effectiveTimeout(override, globalTimeout))
+		actPool.Put(vars)
 
-			if err != nil {
-				fmt.Printf("Evaluation failed: %v\n", err)
+		fmt.Fprintf(&sb, "Static cost: min=%d max=%d\n", result.StaticMinCost, result.StaticMaxCost)
+
+		out := result.Out
+		err := result.Err
+		if err != nil {
+			if result.Exceeded {
+				fmt.Fprintf(&sb, "Evaluation failed: %v (cost budget exceeded)\n", err)
 			} else {
-				fmt.Printf("Result: %v (type: %T)\n", out, out)
+				fmt.Fprintf(&sb, "Evaluation failed: %v\n", err)
+			}
+		} else {
+			if result.ActualCost != nil {
+				fmt.Fprintf(&sb, "Actual cost: %d\n", *result.ActualCost)
 			}
+			fmt.Fprintf(&sb, "Result: %v (type: %T)\n", out, out)
 		}
 	}
+	return sb.String()
 }
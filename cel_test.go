@@ -0,0 +1,144 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+
+	"github.com/Hlinbit/cel-validation/budget"
+	"github.com/Hlinbit/cel-validation/workerpool"
+)
+
+func TestEffectiveMaxCost(t *testing.T) {
+	five := uint64(5)
+	zero := uint64(0)
+
+	cases := []struct {
+		name          string
+		override      budget.Expr
+		globalMaxCost uint64
+		want          *uint64
+	}{
+		{
+			name:          "no override, unlimited global falls back to unlimited",
+			override:      budget.Expr{},
+			globalMaxCost: 0,
+			want:          nil,
+		},
+		{
+			name:          "no override falls back to global default",
+			override:      budget.Expr{},
+			globalMaxCost: 7,
+			want:          uint64Ptr(7),
+		},
+		{
+			name:          "non-zero override wins over global",
+			override:      budget.Expr{MaxCost: &five},
+			globalMaxCost: 7,
+			want:          uint64Ptr(5),
+		},
+		{
+			name:          "# max-cost: 0 override means unlimited, same as the global flag",
+			override:      budget.Expr{MaxCost: &zero},
+			globalMaxCost: 7,
+			want:          nil,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := effectiveMaxCost(tc.override, tc.globalMaxCost)
+			if (got == nil) != (tc.want == nil) {
+				t.Fatalf("effectiveMaxCost() = %v, want %v", got, tc.want)
+			}
+			if got != nil && *got != *tc.want {
+				t.Errorf("effectiveMaxCost() = %d, want %d", *got, *tc.want)
+			}
+		})
+	}
+}
+
+func TestEffectiveTimeout(t *testing.T) {
+	cases := []struct {
+		name          string
+		override      budget.Expr
+		globalTimeout time.Duration
+		want          time.Duration
+	}{
+		{name: "no override falls back to global", override: budget.Expr{}, globalTimeout: time.Second, want: time.Second},
+		{name: "override wins over global", override: budget.Expr{Timeout: 200 * time.Millisecond}, globalTimeout: time.Second, want: 200 * time.Millisecond},
+		{name: "neither set means unlimited", override: budget.Expr{}, globalTimeout: 0, want: 0},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := effectiveTimeout(tc.override, tc.globalTimeout); got != tc.want {
+				t.Errorf("effectiveTimeout() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func uint64Ptr(v uint64) *uint64 { return &v }
+
+func newTestEnv(t *testing.T) *cel.Env {
+	t.Helper()
+	env, err := cel.NewEnv(cel.Declarations(
+		decls.NewVar("object", decls.NewMapType(decls.String, decls.Dyn)),
+		decls.NewVar("params", decls.NewMapType(decls.String, decls.Dyn)),
+	))
+	if err != nil {
+		t.Fatalf("cel.NewEnv() error = %v", err)
+	}
+	return env
+}
+
+// TestRenderObjectResultReusesPreparedProgram exercises the non-benchmark,
+// non-stream path end to end: compile once via prepareExpressions, then
+// render several objects against the same preparedExpr slice, the way
+// workerpool.Map does in main().
+func TestRenderObjectResultReusesPreparedProgram(t *testing.T) {
+	env := newTestEnv(t)
+	expressions := []string{`object.replicas <= params.limit`}
+	overrides := []budget.Expr{{}}
+
+	prepared := prepareExpressions(env, expressions, overrides, 0)
+	if prepared[0].compileErr != "" || prepared[0].prepErr != "" || prepared[0].compiled == nil {
+		t.Fatalf("prepareExpressions() = %+v, want a successfully compiled expression", prepared[0])
+	}
+
+	actPool := workerpool.NewActivationPool()
+	objects := []map[string]interface{}{
+		{"replicas": int64(1)},
+		{"replicas": int64(10)},
+	}
+	params := map[string]interface{}{"limit": int64(3)}
+
+	for i, object := range objects {
+		block := renderObjectResult(object, i, expressions, prepared, overrides, 0, params, actPool)
+		wantResult := "Result: true"
+		if i == 1 {
+			wantResult = "Result: false"
+		}
+		if !strings.Contains(block, wantResult) {
+			t.Errorf("renderObjectResult(object %d) = %q, want it to contain %q", i, block, wantResult)
+		}
+	}
+}
+
+func TestPrepareExpressionsRecordsCompileError(t *testing.T) {
+	env := newTestEnv(t)
+	expressions := []string{`object.replicas <=`}
+	overrides := []budget.Expr{{}}
+
+	prepared := prepareExpressions(env, expressions, overrides, 0)
+	if prepared[0].compileErr == "" {
+		t.Fatal("prepareExpressions() compileErr is empty, want a compile error for a malformed expression")
+	}
+	if prepared[0].compiled != nil {
+		t.Error("prepareExpressions() compiled is non-nil for an expression that failed to compile")
+	}
+}
@@ -0,0 +1,252 @@
+// Package envconfig 解析 CLI flags 与可选的 env.yaml，决定要注册到 CEL 环境上
+// 的扩展库、自定义类型变量以及启用的宏，供 main 在构造 cel.Env 时使用。
+package envconfig
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/ext"
+	"github.com/google/cel-go/parser"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+	"gopkg.in/yaml.v3"
+
+	"github.com/Hlinbit/cel-validation/k8slibs"
+)
+
+// VariableDecl 声明 env.yaml 中 variables 小节的一项：name -> 类型表达式，
+// 例如 "list<string>"、"map<string,dyn>"，或一个 proto message 的完整类型名。
+type VariableDecl struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type"`
+}
+
+// Config 是 env.yaml 反序列化后的结果，三个字段都可选，留空时只使用 CLI 选项。
+type Config struct {
+	Libraries []string       `yaml:"libraries,omitempty"`
+	Variables []VariableDecl `yaml:"variables,omitempty"`
+	Macros    []string       `yaml:"macros,omitempty"`
+}
+
+// Load 读取并解析一份 env.yaml。path 为空时返回零值 Config，方便调用方在未传
+// --config 时直接使用。
+func Load(path string) (*Config, error) {
+	if path == "" {
+		return &Config{}, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read env config %q: %w", path, err)
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse env config %q: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// MergeLibraries 合并 env.yaml 声明的库名与 --lib 传入的库名，去重后返回，
+// 保留首次出现的顺序。
+func (c *Config) MergeLibraries(cliLibs []string) []string {
+	seen := make(map[string]bool)
+	var merged []string
+	for _, name := range append(append([]string{}, c.Libraries...), cliLibs...) {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		merged = append(merged, name)
+	}
+	return merged
+}
+
+// knownLibraries 把库名映射到对应的 cel.EnvOption 构造函数，覆盖 cel-go 自带的
+// 扩展库与本仓库在 k8slibs 中实现的 Kubernetes 风格库。
+var knownLibraries = map[string]func() cel.EnvOption{
+	"strings":   func() cel.EnvOption { return ext.Strings() },
+	"encoders":  func() cel.EnvOption { return ext.Encoders() },
+	"lists":     func() cel.EnvOption { return ext.Lists() },
+	"sets":      func() cel.EnvOption { return ext.Sets() },
+	"math":      func() cel.EnvOption { return ext.Math() },
+	"protos":    func() cel.EnvOption { return ext.Protos() },
+	"quantity":  k8slibs.Quantity,
+	"url":       k8slibs.URL,
+	"regex":     k8slibs.Regex,
+	"k8s-lists": k8slibs.Lists,
+	"authz":     k8slibs.Authz,
+}
+
+// LibraryNames 返回所有可选库名（按字母序），用于 --help 展示以及校验 env.yaml
+// 里的拼写。
+func LibraryNames() []string {
+	names := make([]string, 0, len(knownLibraries))
+	for name := range knownLibraries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ResolveLibraries 把库名列表解析为 cel.EnvOption，遇到未知库名直接报错并列出
+// 可用库名，方便用户排查拼写错误。
+func ResolveLibraries(names []string) ([]cel.EnvOption, error) {
+	var opts []cel.EnvOption
+	for _, name := range names {
+		ctor, ok := knownLibraries[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown CEL library %q (available: %s)", name, strings.Join(LibraryNames(), ", "))
+		}
+		opts = append(opts, ctor())
+	}
+	return opts, nil
+}
+
+// DeclareVariables 把 VariableDecl 列表编译为一个 cel.EnvOption，使用户不必
+// 被迫只用 object/params 这两个固定的 map<string,dyn> 变量。
+func DeclareVariables(vars []VariableDecl) (cel.EnvOption, error) {
+	var ds []*exprpb.Decl
+	for _, v := range vars {
+		t, err := parseType(v.Type)
+		if err != nil {
+			return nil, fmt.Errorf("variable %q: %w", v.Name, err)
+		}
+		ds = append(ds, decls.NewVar(v.Name, t))
+	}
+	return cel.Declarations(ds...), nil
+}
+
+// parseType 把 env.yaml 里的类型表达式解析成 CEL 类型：基础类型关键字、
+// list<T>、map<K,V>，其余一律当作 proto message 的完整类型名处理。
+func parseType(s string) (*exprpb.Type, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "dyn":
+		return decls.Dyn, nil
+	case s == "string":
+		return decls.String, nil
+	case s == "int":
+		return decls.Int, nil
+	case s == "uint":
+		return decls.Uint, nil
+	case s == "double":
+		return decls.Double, nil
+	case s == "bool":
+		return decls.Bool, nil
+	case s == "bytes":
+		return decls.Bytes, nil
+	case s == "timestamp":
+		return decls.Timestamp, nil
+	case s == "duration":
+		return decls.Duration, nil
+	case strings.HasPrefix(s, "list<") && strings.HasSuffix(s, ">"):
+		inner, err := parseType(s[len("list<") : len(s)-1])
+		if err != nil {
+			return nil, err
+		}
+		return decls.NewListType(inner), nil
+	case strings.HasPrefix(s, "map<") && strings.HasSuffix(s, ">"):
+		keyExpr, valExpr, err := splitMapArgs(s[len("map<") : len(s)-1])
+		if err != nil {
+			return nil, err
+		}
+		key, err := parseType(keyExpr)
+		if err != nil {
+			return nil, err
+		}
+		val, err := parseType(valExpr)
+		if err != nil {
+			return nil, err
+		}
+		return decls.NewMapType(key, val), nil
+	default:
+		return decls.NewObjectType(s), nil
+	}
+}
+
+// splitMapArgs 把 "K,V" 在顶层逗号处拆开，允许 K 或 V 自身是嵌套的 list<>/map<>。
+func splitMapArgs(s string) (string, string, error) {
+	depth := 0
+	for i, r := range s {
+		switch r {
+		case '<':
+			depth++
+		case '>':
+			depth--
+		case ',':
+			if depth == 0 {
+				return s[:i], s[i+1:], nil
+			}
+		}
+	}
+	return "", "", fmt.Errorf("invalid map type %q: expected map<K,V>", s)
+}
+
+// functionOwners 记录每个扩展库对外暴露的函数名，用于在编译报错时提示用户
+// "这个函数属于哪个库"，而不是让用户自己去翻 cel-go 文档。key 必须是 cel-go
+// 编译错误里 "undeclared reference to '<fn>'" 实际会打印的那个函数名——不是
+// overload id（如 `indexOf_int`），也不是凭空猜测归属（如把只有 k8s-lists 才
+// 定义的 `sum` 挂到 math 库下）。当同一个函数名被多个库以不同调用形态
+// （receiver-style 方法 vs 自由函数）各自定义一份重名 overload 时，value 用
+// 逗号分隔列出全部候选库名——跟 --lib 本身接受逗号分隔多个库名的写法一致，
+// 这样至少不会把用户导向一个确定修不好编译错误的库。
+var functionOwners = map[string]string{
+	"charAt": "strings", "join": "strings", "lowerAscii": "strings",
+	"replace": "strings", "split": "strings", "substring": "strings", "trim": "strings", "upperAscii": "strings",
+	"base64.encode": "encoders", "base64.decode": "encoders",
+	"distinct": "lists", "flatten": "lists", "sortBy": "lists",
+	"intersect": "sets", "union": "sets", "equivalent": "sets",
+	"quantity": "quantity", "isQuantity": "quantity",
+	"isURL": "url", "getHostname": "url",
+	"find": "regex", "findAll": "regex",
+	"sum": "k8s-lists", "isSorted": "k8s-lists",
+	// indexOf/lastIndexOf 同时是 strings（<string>.indexOf(...) 方法）与
+	// k8s-lists（indexOf(list, x) 自由函数）各自重名的 overload，无法仅凭
+	// 函数名分辨用户调用的是哪一种形态。
+	"indexOf": "strings,k8s-lists", "lastIndexOf": "strings,k8s-lists",
+	"authz_allowed": "authz",
+}
+
+// SuggestLibraryForError 在一条 CEL 编译错误信息里查找 "undeclared reference
+// to '<fn>'" 之类的提示，并回答这个函数原本应该由哪个库提供；找不到已知归属时
+// 返回空字符串。某个函数名被多个库以不同调用形态重名定义时，返回值是逗号分隔
+// 的候选库名列表，可以直接拼进 --lib（同样接受逗号分隔）。
+func SuggestLibraryForError(errMsg string) string {
+	for fn, lib := range functionOwners {
+		if strings.Contains(errMsg, "'"+fn+"'") {
+			return lib
+		}
+	}
+	return ""
+}
+
+// stdMacrosByFunction 按函数名索引 CEL 标准宏，供 SelectMacros 查找。
+func stdMacrosByFunction() map[string]cel.Macro {
+	m := make(map[string]cel.Macro, len(parser.AllMacros))
+	for _, mac := range parser.AllMacros {
+		m[mac.Function()] = mac
+	}
+	return m
+}
+
+// SelectMacros 把 env.yaml 里声明的宏名解析为限制标准宏集合的 cel.EnvOption；
+// names 为空时返回 nil，即沿用 cel.NewEnv 默认启用的全部标准宏。
+func SelectMacros(names []string) ([]cel.EnvOption, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	available := stdMacrosByFunction()
+	var selected []cel.Macro
+	for _, name := range names {
+		mac, ok := available[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown macro %q", name)
+		}
+		selected = append(selected, mac)
+	}
+	return []cel.EnvOption{cel.ClearMacros(), cel.Macros(selected...)}, nil
+}
@@ -0,0 +1,116 @@
+// Package golden 实现 --assert/--update 用到的"预期结果"前导指令：
+// `# expect: <yaml值>` 与 `# expect-error: <子串>`，负责把它们从表达式块里摘
+// 出来、跟实际求值结果做比对并打印 kr/pretty 风格的 diff，以及在 --update 时
+// 把观测到的结果重新格式化成指令行写回表达式文件。
+//
+// 一条表达式的预期结果被当成它在全部 object 上都应该成立的性质（例如"这条
+// 校验表达式在所有样例 object 上都应该是 true"），而不是跟某一个 object 按下标
+// 配对——这样才能对得上现有 object × expression 全量交叉求值的执行模型。
+package golden
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kr/pretty"
+	"gopkg.in/yaml.v3"
+)
+
+// Expectation 是从表达式块里解析出的预期结果。同一块里两种指令都出现时
+// HasResult 与 HasError 都为 true，Matches/Diff 以 HasError 为准。
+type Expectation struct {
+	HasResult bool
+	Result    interface{}
+
+	HasError       bool
+	ErrorSubstring string
+}
+
+// Extract 从 block 的任意位置挑出 `# expect:`/`# expect-error:` 这两种指令行并摘除，
+// 其余所有行（包括 budget 包认识的 `# max-cost:`/`# timeout:`，以及表达式正文）
+// 原样保留在 rest 中，交给 budget.ParseExpression 继续处理。没有任何 expect
+// 指令时 exp 返回 nil。
+func Extract(block string) (rest string, exp *Expectation, err error) {
+	lines := strings.Split(block, "\n")
+	var kept []string
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "#") {
+			kept = append(kept, line)
+			continue
+		}
+
+		directive := strings.TrimSpace(strings.TrimPrefix(trimmed, "#"))
+		if v, ok := cutPrefix(directive, "expect-error:"); ok {
+			if exp == nil {
+				exp = &Expectation{}
+			}
+			exp.HasError = true
+			exp.ErrorSubstring = strings.TrimSpace(v)
+			continue
+		}
+		if v, ok := cutPrefix(directive, "expect:"); ok {
+			var val interface{}
+			if err := yaml.Unmarshal([]byte(v), &val); err != nil {
+				return "", nil, fmt.Errorf("invalid `# expect:` value %q: %w", v, err)
+			}
+			if exp == nil {
+				exp = &Expectation{}
+			}
+			exp.HasResult = true
+			exp.Result = val
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return strings.Join(kept, "\n"), exp, nil
+}
+
+func cutPrefix(s, prefix string) (string, bool) {
+	if !strings.HasPrefix(s, prefix) {
+		return "", false
+	}
+	return s[len(prefix):], true
+}
+
+// Matches 判断一次实际求值结果是否符合 exp 的预期。
+func (exp *Expectation) Matches(out interface{}, evalErr error) bool {
+	if exp.HasError {
+		return evalErr != nil && strings.Contains(evalErr.Error(), exp.ErrorSubstring)
+	}
+	if evalErr != nil {
+		return false
+	}
+	return fmt.Sprintf("%v", exp.Result) == fmt.Sprintf("%v", out)
+}
+
+// Diff 返回 exp 与实际求值结果之间 kr/pretty 风格的差异描述，用于在断言失败时
+// 打印给用户看。
+func (exp *Expectation) Diff(out interface{}, evalErr error) []string {
+	if exp.HasError {
+		want := fmt.Sprintf("error containing %q", exp.ErrorSubstring)
+		got := "no error"
+		if evalErr != nil {
+			got = fmt.Sprintf("error %q", evalErr.Error())
+		}
+		return pretty.Diff(want, got)
+	}
+	var got interface{} = out
+	if evalErr != nil {
+		got = fmt.Sprintf("error %q", evalErr.Error())
+	}
+	return pretty.Diff(exp.Result, got)
+}
+
+// Directive 把一次观测到的求值结果格式化成可以写回表达式文件的
+// `# expect:`/`# expect-error:` 指令行，供 --update 使用。
+func Directive(out interface{}, evalErr error) (string, error) {
+	if evalErr != nil {
+		return fmt.Sprintf("# expect-error: %s", evalErr.Error()), nil
+	}
+	data, err := yaml.Marshal(out)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode observed result: %w", err)
+	}
+	return "# expect: " + strings.TrimSpace(string(data)), nil
+}
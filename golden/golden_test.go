@@ -0,0 +1,121 @@
+package golden
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	t.Run("expect result", func(t *testing.T) {
+		block := "# expect: true\nobject.replicas <= params.limit"
+		rest, exp, err := Extract(block)
+		if err != nil {
+			t.Fatalf("Extract() error = %v", err)
+		}
+		if rest != "object.replicas <= params.limit" {
+			t.Errorf("rest = %q", rest)
+		}
+		if exp == nil || !exp.HasResult || exp.Result != true {
+			t.Fatalf("exp = %+v, want HasResult=true Result=true", exp)
+		}
+	})
+
+	t.Run("expect error", func(t *testing.T) {
+		block := "# expect-error: division by zero\n1/0"
+		rest, exp, err := Extract(block)
+		if err != nil {
+			t.Fatalf("Extract() error = %v", err)
+		}
+		if rest != "1/0" {
+			t.Errorf("rest = %q", rest)
+		}
+		if exp == nil || !exp.HasError || exp.ErrorSubstring != "division by zero" {
+			t.Fatalf("exp = %+v", exp)
+		}
+	})
+
+	t.Run("no expectation leaves other headers intact", func(t *testing.T) {
+		block := "# max-cost: 10\nobject.replicas <= params.limit"
+		rest, exp, err := Extract(block)
+		if err != nil {
+			t.Fatalf("Extract() error = %v", err)
+		}
+		if rest != block {
+			t.Errorf("rest = %q, want unchanged %q", rest, block)
+		}
+		if exp != nil {
+			t.Errorf("exp = %+v, want nil", exp)
+		}
+	})
+
+	t.Run("invalid yaml value", func(t *testing.T) {
+		block := "# expect: [unterminated\nobject.x"
+		if _, _, err := Extract(block); err == nil {
+			t.Fatal("Extract() error = nil, want error for invalid YAML value")
+		}
+	})
+}
+
+func TestExpectationMatches(t *testing.T) {
+	resultExp := &Expectation{HasResult: true, Result: true}
+	if !resultExp.Matches(true, nil) {
+		t.Error("Matches(true, nil) = false, want true")
+	}
+	if resultExp.Matches(false, nil) {
+		t.Error("Matches(false, nil) = true, want false")
+	}
+	if resultExp.Matches(true, errors.New("boom")) {
+		t.Error("Matches(true, err) = true, want false when an error occurred")
+	}
+
+	errExp := &Expectation{HasError: true, ErrorSubstring: "no such key"}
+	if !errExp.Matches(nil, errors.New(`no such key: "foo"`)) {
+		t.Error("Matches() = false, want true when error contains the expected substring")
+	}
+	if errExp.Matches(nil, errors.New("unrelated failure")) {
+		t.Error("Matches() = true, want false when error does not contain the expected substring")
+	}
+	if errExp.Matches(nil, nil) {
+		t.Error("Matches() = true, want false when no error occurred at all")
+	}
+}
+
+func TestDirective(t *testing.T) {
+	directive, err := Directive(true, nil)
+	if err != nil {
+		t.Fatalf("Directive() error = %v", err)
+	}
+	if !strings.HasPrefix(directive, "# expect:") {
+		t.Errorf("Directive() = %q, want prefix `# expect:`", directive)
+	}
+
+	directive, err = Directive(nil, errors.New("boom"))
+	if err != nil {
+		t.Fatalf("Directive() error = %v", err)
+	}
+	if directive != "# expect-error: boom" {
+		t.Errorf("Directive() = %q, want %q", directive, "# expect-error: boom")
+	}
+}
+
+// TestExtractDirectiveRoundTrip makes sure a directive formatted by Directive
+// can be parsed back by Extract into an equivalent Expectation, the way
+// --update immediately followed by --assert should behave.
+func TestExtractDirectiveRoundTrip(t *testing.T) {
+	directive, err := Directive(42, nil)
+	if err != nil {
+		t.Fatalf("Directive() error = %v", err)
+	}
+
+	_, exp, err := Extract(directive + "\nobject.replicas")
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if exp == nil || !exp.HasResult {
+		t.Fatalf("exp = %+v, want HasResult=true", exp)
+	}
+	if !exp.Matches(42, nil) {
+		t.Errorf("round-tripped expectation does not match the original observed value")
+	}
+}
@@ -0,0 +1,260 @@
+// Package k8slibs 提供离线环境下的 Kubernetes 风格 CEL 扩展库（quantity、url、
+// regex、lists、authz），对应 CRD/ValidatingAdmissionPolicy 求值时常见的那一批
+// 库。这里没有依赖真正的 k8s.io/apiserver，因此只实现了日常校验表达式会用到的
+// 子集，行为力求贴近官方库但不追求逐字节一致。
+package k8slibs
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+)
+
+// quantitySuffixes 把 Kubernetes resource.Quantity 里常见的单位后缀换算成倍率。
+var quantitySuffixes = map[string]float64{
+	"n": 1e-9, "u": 1e-6, "m": 1e-3,
+	"k": 1e3, "K": 1e3, "M": 1e6, "G": 1e9, "T": 1e12, "P": 1e15, "E": 1e18,
+	"Ki": 1 << 10, "Mi": 1 << 20, "Gi": 1 << 30, "Ti": 1 << 40, "Pi": 1 << 50, "Ei": 1 << 60,
+}
+
+// parseQuantity 把形如 "500m"、"2Gi"、"1.5" 的字符串解析为基准单位下的数值。
+func parseQuantity(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	for _, suffix := range []string{"Ki", "Mi", "Gi", "Ti", "Pi", "Ei", "n", "u", "m", "k", "K", "M", "G", "T", "P", "E"} {
+		if strings.HasSuffix(s, suffix) {
+			numPart := strings.TrimSuffix(s, suffix)
+			v, err := strconv.ParseFloat(numPart, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid quantity %q: %w", s, err)
+			}
+			return v * quantitySuffixes[suffix], nil
+		}
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid quantity %q: %w", s, err)
+	}
+	return v, nil
+}
+
+// Quantity 注册 `quantity(string) double` 与 `isQuantity(string) bool`，
+// 对应 k8s CEL 库里 `quantity()` 构造函数常见的基础用法：把资源量字符串换算成
+// 基准单位下的数值，便于与阈值比较。
+func Quantity() cel.EnvOption {
+	return cel.Lib(quantityLib{})
+}
+
+type quantityLib struct{}
+
+func (quantityLib) CompileOptions() []cel.EnvOption {
+	return []cel.EnvOption{
+		cel.Function("quantity",
+			cel.Overload("quantity_string", []*cel.Type{cel.StringType}, cel.DoubleType,
+				cel.UnaryBinding(func(arg ref.Val) ref.Val {
+					v, err := parseQuantity(string(arg.(types.String)))
+					if err != nil {
+						return types.NewErr("%v", err)
+					}
+					return types.Double(v)
+				}),
+			),
+		),
+		cel.Function("isQuantity",
+			cel.Overload("is_quantity_string", []*cel.Type{cel.StringType}, cel.BoolType,
+				cel.UnaryBinding(func(arg ref.Val) ref.Val {
+					_, err := parseQuantity(string(arg.(types.String)))
+					return types.Bool(err == nil)
+				}),
+			),
+		),
+	}
+}
+
+func (quantityLib) ProgramOptions() []cel.ProgramOption {
+	return nil
+}
+
+// URL 注册 `isURL(string) bool` 与 `getHostname(string) string`，覆盖
+// ValidatingAdmissionPolicy 里校验 URL 字段时最常见的两种用法。
+func URL() cel.EnvOption {
+	return cel.Lib(urlLib{})
+}
+
+type urlLib struct{}
+
+func (urlLib) CompileOptions() []cel.EnvOption {
+	return []cel.EnvOption{
+		cel.Function("isURL",
+			cel.Overload("is_url_string", []*cel.Type{cel.StringType}, cel.BoolType,
+				cel.UnaryBinding(func(arg ref.Val) ref.Val {
+					_, err := url.ParseRequestURI(string(arg.(types.String)))
+					return types.Bool(err == nil)
+				}),
+			),
+		),
+		cel.Function("getHostname",
+			cel.Overload("get_hostname_string", []*cel.Type{cel.StringType}, cel.StringType,
+				cel.UnaryBinding(func(arg ref.Val) ref.Val {
+					u, err := url.ParseRequestURI(string(arg.(types.String)))
+					if err != nil {
+						return types.NewErr("%v", err)
+					}
+					return types.String(u.Hostname())
+				}),
+			),
+		),
+	}
+}
+
+func (urlLib) ProgramOptions() []cel.ProgramOption {
+	return nil
+}
+
+// Regex 注册 `find(string, string) string` 与 `findAll(string, string) list<string>`，
+// 补充 CEL 标准库里 `matches` 没有覆盖的"取匹配内容"场景。
+func Regex() cel.EnvOption {
+	return cel.Lib(regexLib{})
+}
+
+type regexLib struct{}
+
+func (regexLib) CompileOptions() []cel.EnvOption {
+	return []cel.EnvOption{
+		cel.Function("find",
+			cel.Overload("find_string_string", []*cel.Type{cel.StringType, cel.StringType}, cel.StringType,
+				cel.BinaryBinding(func(str, re ref.Val) ref.Val {
+					r, err := regexp.Compile(string(re.(types.String)))
+					if err != nil {
+						return types.NewErr("%v", err)
+					}
+					return types.String(r.FindString(string(str.(types.String))))
+				}),
+			),
+		),
+		cel.Function("findAll",
+			cel.Overload("find_all_string_string", []*cel.Type{cel.StringType, cel.StringType}, cel.ListType(cel.StringType),
+				cel.BinaryBinding(func(str, re ref.Val) ref.Val {
+					r, err := regexp.Compile(string(re.(types.String)))
+					if err != nil {
+						return types.NewErr("%v", err)
+					}
+					matches := r.FindAllString(string(str.(types.String)), -1)
+					return types.NewStringList(types.DefaultTypeAdapter, matches)
+				}),
+			),
+		),
+	}
+}
+
+func (regexLib) ProgramOptions() []cel.ProgramOption {
+	return nil
+}
+
+// Lists 注册 k8s CEL 库里最常用的几个 list 辅助函数：isSorted、sum、indexOf、
+// lastIndexOf，均按 int 列表实现（VAP 策略里最常见的用法）。
+func Lists() cel.EnvOption {
+	return cel.Lib(listsLib{})
+}
+
+type listsLib struct{}
+
+func (listsLib) CompileOptions() []cel.EnvOption {
+	intList := cel.ListType(cel.IntType)
+	return []cel.EnvOption{
+		cel.Function("isSorted",
+			cel.Overload("is_sorted_list_int", []*cel.Type{intList}, cel.BoolType,
+				cel.UnaryBinding(func(arg ref.Val) ref.Val {
+					items := toInt64Slice(arg)
+					return types.Bool(sort.SliceIsSorted(items, func(i, j int) bool { return items[i] < items[j] }))
+				}),
+			),
+		),
+		cel.Function("sum",
+			cel.Overload("sum_list_int", []*cel.Type{intList}, cel.IntType,
+				cel.UnaryBinding(func(arg ref.Val) ref.Val {
+					var total int64
+					for _, v := range toInt64Slice(arg) {
+						total += v
+					}
+					return types.Int(total)
+				}),
+			),
+		),
+		cel.Function("indexOf",
+			cel.Overload("index_of_list_int_int", []*cel.Type{intList, cel.IntType}, cel.IntType,
+				cel.BinaryBinding(func(list, needle ref.Val) ref.Val {
+					items := toInt64Slice(list)
+					target := int64(needle.(types.Int))
+					for i, v := range items {
+						if v == target {
+							return types.Int(i)
+						}
+					}
+					return types.Int(-1)
+				}),
+			),
+		),
+		cel.Function("lastIndexOf",
+			cel.Overload("last_index_of_list_int_int", []*cel.Type{intList, cel.IntType}, cel.IntType,
+				cel.BinaryBinding(func(list, needle ref.Val) ref.Val {
+					items := toInt64Slice(list)
+					target := int64(needle.(types.Int))
+					for i := len(items) - 1; i >= 0; i-- {
+						if items[i] == target {
+							return types.Int(i)
+						}
+					}
+					return types.Int(-1)
+				}),
+			),
+		),
+	}
+}
+
+func (listsLib) ProgramOptions() []cel.ProgramOption {
+	return nil
+}
+
+func toInt64Slice(v ref.Val) []int64 {
+	lister := v.(traits.Lister)
+	size := int(lister.Size().(types.Int))
+	out := make([]int64, size)
+	for i := 0; i < size; i++ {
+		out[i] = int64(lister.Get(types.Int(i)).(types.Int))
+	}
+	return out
+}
+
+// Authz 注册一个最小化的 `authz_allowed(string) bool`，作为 k8s `authz` 库的
+// 占位实现：真实的 authorizer CEL 库会发起 SubjectAccessReview，这里没有一个
+// 真实的授权后端可对接，因此对任何输入都固定返回 false，仅足以离线验证表达式
+// 语法与逻辑走向，不能替代真正的鉴权检查。
+func Authz() cel.EnvOption {
+	return cel.Lib(authzLib{})
+}
+
+type authzLib struct{}
+
+func (authzLib) CompileOptions() []cel.EnvOption {
+	return []cel.EnvOption{
+		cel.Function("authz_allowed",
+			cel.Overload("authz_allowed_string", []*cel.Type{cel.StringType}, cel.BoolType,
+				cel.UnaryBinding(func(arg ref.Val) ref.Val {
+					return types.Bool(false)
+				}),
+			),
+		),
+	}
+}
+
+func (authzLib) ProgramOptions() []cel.ProgramOption {
+	return nil
+}
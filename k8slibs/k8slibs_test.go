@@ -0,0 +1,101 @@
+package k8slibs
+
+import (
+	"testing"
+
+	"github.com/google/cel-go/cel"
+)
+
+func TestParseQuantity(t *testing.T) {
+	cases := []struct {
+		name    string
+		in      string
+		want    float64
+		wantErr bool
+	}{
+		{name: "plain number", in: "1.5", want: 1.5},
+		{name: "milli suffix", in: "500m", want: 0.5},
+		{name: "kibi suffix", in: "2Ki", want: 2 * 1024},
+		{name: "mebi suffix", in: "1Mi", want: 1 << 20},
+		{name: "giga suffix", in: "1G", want: 1e9},
+		{name: "whitespace is trimmed", in: "  1Gi  ", want: 1 << 30},
+		{name: "invalid", in: "not-a-quantity", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseQuantity(tc.in)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("parseQuantity(%q) error = nil, want error", tc.in)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseQuantity(%q) error = %v", tc.in, err)
+			}
+			if got != tc.want {
+				t.Errorf("parseQuantity(%q) = %v, want %v", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+// evalBool compiles and evaluates expr against an environment with all
+// k8slibs libraries registered, and asserts the result is the bool want.
+func evalBool(t *testing.T, expr string, want bool) {
+	t.Helper()
+	env, err := cel.NewEnv(Quantity(), URL(), Regex(), Lists(), Authz())
+	if err != nil {
+		t.Fatalf("cel.NewEnv() error = %v", err)
+	}
+	ast, issues := env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		t.Fatalf("Compile(%q) error = %v", expr, issues.Err())
+	}
+	prg, err := env.Program(ast)
+	if err != nil {
+		t.Fatalf("Program() error = %v", err)
+	}
+	out, _, err := prg.Eval(cel.NoVars())
+	if err != nil {
+		t.Fatalf("Eval(%q) error = %v", expr, err)
+	}
+	got, ok := out.Value().(bool)
+	if !ok {
+		t.Fatalf("Eval(%q) = %v (%T), want bool", expr, out.Value(), out.Value())
+	}
+	if got != want {
+		t.Errorf("Eval(%q) = %v, want %v", expr, got, want)
+	}
+}
+
+func TestLibraryOverloads(t *testing.T) {
+	cases := []struct {
+		name string
+		expr string
+		want bool
+	}{
+		{name: "quantity comparison", expr: `quantity("500m") < quantity("1")`, want: true},
+		{name: "isQuantity true", expr: `isQuantity("2Gi")`, want: true},
+		{name: "isQuantity false", expr: `isQuantity("nope")`, want: false},
+		{name: "isURL true", expr: `isURL("https://example.com/path")`, want: true},
+		{name: "isURL false", expr: `isURL("not a url")`, want: false},
+		{name: "getHostname", expr: `getHostname("https://example.com/path") == "example.com"`, want: true},
+		{name: "find", expr: `find("hello123", "[0-9]+") == "123"`, want: true},
+		{name: "findAll", expr: `findAll("a1b2c3", "[0-9]") == ["1", "2", "3"]`, want: true},
+		{name: "isSorted true", expr: `isSorted([1, 2, 3])`, want: true},
+		{name: "isSorted false", expr: `isSorted([3, 1, 2])`, want: false},
+		{name: "sum", expr: `sum([1, 2, 3]) == 6`, want: true},
+		{name: "indexOf found", expr: `indexOf([5, 6, 7], 6) == 1`, want: true},
+		{name: "indexOf not found", expr: `indexOf([5, 6, 7], 9) == -1`, want: true},
+		{name: "lastIndexOf", expr: `lastIndexOf([1, 2, 1], 1) == 2`, want: true},
+		{name: "authz_allowed is a fixed placeholder", expr: `authz_allowed("anything") == false`, want: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			evalBool(t, tc.expr, tc.want)
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package policy
+
+import (
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+)
+
+// BuildEnv 基于 bundle 声明的 paramKind 构造一个 CEL 环境：除了通用工具沿用的
+// object/oldObject 之外，再注册 variables 命名空间，并按 spec.paramKind 是否
+// 声明来决定要不要注册 params——跟真实的 ValidatingAdmissionPolicy 语义一致，
+// 没有 paramKind 就没有绑定的参数资源，表达式里引用 params 应该在编译期就报错，
+// 而不是静默地拿到一个空 map。paramKind 声明时，由于离线场景下没有真实的 CRD
+// schema 可用于推导具体字段，仍然退化为 map<string,dyn>。extra 允许调用方附加
+// 额外的 cel.EnvOption（扩展库、自定义类型等）。
+func BuildEnv(b *Bundle, extra ...cel.EnvOption) (*cel.Env, error) {
+	opts := []cel.EnvOption{
+		cel.Declarations(
+			decls.NewVar("object", decls.NewMapType(decls.String, decls.Dyn)),
+			decls.NewVar("oldObject", decls.NewMapType(decls.String, decls.Dyn)),
+			decls.NewVar("variables", decls.NewMapType(decls.String, decls.Dyn)),
+		),
+	}
+	if b.Spec.ParamKind != nil {
+		opts = append(opts, cel.Declarations(
+			decls.NewVar("params", decls.NewMapType(decls.String, decls.Dyn)),
+		))
+	}
+	opts = append(opts, extra...)
+
+	env, err := cel.NewEnv(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build policy environment: %w", err)
+	}
+	return env, nil
+}
+
+// activation 是本包内部在求值期间复用的求值上下文。
+type activation struct {
+	object    map[string]interface{}
+	oldObject map[string]interface{}
+	params    map[string]interface{}
+	variables map[string]interface{}
+}
+
+func (a activation) vars() map[string]interface{} {
+	return map[string]interface{}{
+		"object":    a.object,
+		"oldObject": a.oldObject,
+		"params":    a.params,
+		"variables": a.variables,
+	}
+}
+
+// compiledExpr 是某条表达式编译并 planning 之后的结果，可以反复 eval 而不必
+// 每次都重新编译——跟 budget.Compiled 是同一个思路。
+type compiledExpr struct {
+	program cel.Program
+}
+
+func compile(env *cel.Env, expr string) (compiledExpr, error) {
+	ast, issues := env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return compiledExpr{}, fmt.Errorf("compilation failed: %w", issues.Err())
+	}
+	program, err := env.Program(ast)
+	if err != nil {
+		return compiledExpr{}, fmt.Errorf("failed to create program: %w", err)
+	}
+	return compiledExpr{program: program}, nil
+}
+
+func (c compiledExpr) eval(vars map[string]interface{}) (interface{}, error) {
+	out, _, err := c.program.Eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	return out.Value(), nil
+}
+
+// Prepared 持有一份 bundle 里全部表达式（variables、matchConditions、
+// validations 及其 messageExpression）预先编译、planning 好的 cel.Program，
+// 让 Eval 可以在对象的热路径上反复调用而不用每个 object 都重新编译一遍——跟
+// budget.Compiled/prepareExpressions 是同一套"编译一次、求值多次"的模式。
+type Prepared struct {
+	bundle          *Bundle
+	orderedVars     []Variable
+	varExprs        []compiledExpr
+	matchConditions []compiledExpr
+	validations     []compiledExpr
+	// messageExprs 与 validations 等长；没有 messageExpression 的 validation
+	// 对应位置是 compiledExpr 零值，不会被用到。
+	messageExprs []compiledExpr
+}
+
+// Prepare 编译 bundle 中的全部表达式一次，供后续对多个 object 重复调用 Eval。
+func Prepare(env *cel.Env, b *Bundle) (*Prepared, error) {
+	orderedVars, err := OrderedVariables(b.Spec.Variables)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Prepared{bundle: b, orderedVars: orderedVars}
+
+	for _, v := range orderedVars {
+		c, err := compile(env, v.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile variable %q: %w", v.Name, err)
+		}
+		p.varExprs = append(p.varExprs, c)
+	}
+
+	for _, mc := range b.Spec.MatchConditions {
+		c, err := compile(env, mc.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile matchCondition %q: %w", mc.Name, err)
+		}
+		p.matchConditions = append(p.matchConditions, c)
+	}
+
+	for _, validation := range b.Spec.Validations {
+		c, err := compile(env, validation.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile validation %q: %w", validation.Expression, err)
+		}
+		p.validations = append(p.validations, c)
+
+		var msgC compiledExpr
+		if validation.MessageExpression != "" {
+			msgC, err = compile(env, validation.MessageExpression)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compile messageExpression for validation %q: %w", validation.Expression, err)
+			}
+		}
+		p.messageExprs = append(p.messageExprs, msgC)
+	}
+
+	return p, nil
+}
+
+// Eval 对一个 object 求值整份策略：先解析 variables（按依赖顺序），
+// 再依次检查 matchConditions，只有全部为 true 才会继续求值 validations。
+func (p *Prepared) Eval(object, oldObject, params map[string]interface{}) (*Result, error) {
+	act := activation{
+		object:    object,
+		oldObject: oldObject,
+		params:    params,
+		variables: map[string]interface{}{},
+	}
+
+	for i, v := range p.orderedVars {
+		val, err := p.varExprs[i].eval(act.vars())
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve variable %q: %w", v.Name, err)
+		}
+		act.variables[v.Name] = val
+	}
+
+	result := &Result{Matched: true}
+	for i, mc := range p.bundle.Spec.MatchConditions {
+		val, err := p.matchConditions[i].eval(act.vars())
+		if err != nil {
+			result.Matched = false
+			result.MatchConditionErr = fmt.Errorf("matchCondition %q: %w", mc.Name, err)
+			return result, nil
+		}
+		matched, ok := val.(bool)
+		if !ok {
+			result.Matched = false
+			result.MatchConditionErr = fmt.Errorf("matchCondition %q did not evaluate to bool", mc.Name)
+			return result, nil
+		}
+		if !matched {
+			result.Matched = false
+			return result, nil
+		}
+	}
+
+	for i, validation := range p.bundle.Spec.Validations {
+		outcome := Outcome{Validation: validation}
+
+		val, err := p.validations[i].eval(act.vars())
+		if err != nil {
+			outcome.Error = err
+			result.Outcomes = append(result.Outcomes, outcome)
+			continue
+		}
+		passed, ok := val.(bool)
+		if !ok {
+			outcome.Error = fmt.Errorf("validation expression did not evaluate to bool")
+			result.Outcomes = append(result.Outcomes, outcome)
+			continue
+		}
+		outcome.Passed = passed
+		outcome.Reason = validation.Reason
+
+		if !passed {
+			switch {
+			case validation.MessageExpression != "":
+				msg, err := p.messageExprs[i].eval(act.vars())
+				if err != nil {
+					outcome.Error = fmt.Errorf("messageExpression failed: %w", err)
+				} else if s, ok := msg.(string); ok {
+					outcome.Message = s
+				} else {
+					outcome.Message = fmt.Sprintf("%v", msg)
+				}
+			case validation.Message != "":
+				outcome.Message = validation.Message
+			}
+		}
+
+		result.Outcomes = append(result.Outcomes, outcome)
+	}
+
+	return result, nil
+}
+
+// Evaluate 是 Prepare+Eval 的单次调用便捷包装，适合只需要对一个 object 求值
+// 一次的场景；对同一份 bundle 求值多个 object 时应改为调用一次 Prepare，再对
+// 每个 object 重复调用 Eval，避免在热路径上重新编译表达式。
+func Evaluate(env *cel.Env, b *Bundle, object, oldObject, params map[string]interface{}) (*Result, error) {
+	prepared, err := Prepare(env, b)
+	if err != nil {
+		return nil, err
+	}
+	return prepared.Eval(object, oldObject, params)
+}
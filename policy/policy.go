@@ -0,0 +1,149 @@
+// Package policy 解析并求值 Kubernetes ValidatingAdmissionPolicy 风格的 YAML 清单，
+// 使其可以离线加载 spec.validations/matchConditions/variables 并在 CEL 环境中求值。
+package policy
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ParamKind 对应 spec.paramKind，声明该策略绑定的参数资源类型。
+type ParamKind struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+}
+
+// Variable 对应 spec.variables 中的一项，name 可在后续的 variables/matchConditions/
+// validations 表达式中以 `variables.<name>` 的形式引用。
+type Variable struct {
+	Name       string `yaml:"name"`
+	Expression string `yaml:"expression"`
+}
+
+// MatchCondition 对应 spec.matchConditions 中的一项，所有条件都必须为 true，
+// 对应的 validations 才会被求值。
+type MatchCondition struct {
+	Name       string `yaml:"name"`
+	Expression string `yaml:"expression"`
+}
+
+// Validation 对应 spec.validations 中的一项。
+type Validation struct {
+	Expression        string `yaml:"expression"`
+	Message           string `yaml:"message,omitempty"`
+	MessageExpression string `yaml:"messageExpression,omitempty"`
+	Reason            string `yaml:"reason,omitempty"`
+}
+
+// Spec 对应 ValidatingAdmissionPolicy 的 spec 字段，只保留离线求值需要的子集。
+type Spec struct {
+	ParamKind       *ParamKind       `yaml:"paramKind,omitempty"`
+	Variables       []Variable       `yaml:"variables,omitempty"`
+	MatchConditions []MatchCondition `yaml:"matchConditions,omitempty"`
+	Validations     []Validation     `yaml:"validations"`
+}
+
+// Metadata 只保留 name，用于结果输出时标识策略。
+type Metadata struct {
+	Name string `yaml:"name"`
+}
+
+// Bundle 是一份 ValidatingAdmissionPolicy YAML 清单反序列化后的结果。
+type Bundle struct {
+	APIVersion string   `yaml:"apiVersion"`
+	Kind       string   `yaml:"kind"`
+	Metadata   Metadata `yaml:"metadata"`
+	Spec       Spec     `yaml:"spec"`
+}
+
+// ParseBundle 解析一份 ValidatingAdmissionPolicy YAML 清单。
+func ParseBundle(data []byte) (*Bundle, error) {
+	var b Bundle
+	if err := yaml.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("failed to parse policy bundle: %w", err)
+	}
+	if b.Kind != "" && b.Kind != "ValidatingAdmissionPolicy" {
+		return nil, fmt.Errorf("unsupported kind %q, expected ValidatingAdmissionPolicy", b.Kind)
+	}
+	return &b, nil
+}
+
+// OrderedVariables 返回按依赖顺序排列的 variables：若 variables[j] 的表达式引用了
+// variables[i]（形如 `variables.<name>`），则 i 必须排在 j 之前。声明顺序中已满足
+// 依赖的情况下保持原序，只有在检测到引用晚声明变量时才会重新排序。
+func OrderedVariables(vars []Variable) ([]Variable, error) {
+	var ordered []Variable
+	resolved := make(map[string]bool, len(vars))
+	var visit func(v Variable, stack map[string]bool) error
+	visit = func(v Variable, stack map[string]bool) error {
+		if resolved[v.Name] {
+			return nil
+		}
+		if stack[v.Name] {
+			return fmt.Errorf("circular variable reference involving %q", v.Name)
+		}
+		stack[v.Name] = true
+		for _, dep := range vars {
+			if dep.Name == v.Name {
+				continue
+			}
+			if referencesVariable(v.Expression, dep.Name) {
+				if err := visit(dep, stack); err != nil {
+					return err
+				}
+			}
+		}
+		delete(stack, v.Name)
+		resolved[v.Name] = true
+		ordered = append(ordered, v)
+		return nil
+	}
+
+	for _, v := range vars {
+		if err := visit(v, map[string]bool{}); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// referencesVariable 粗略检测 expr 中是否引用了 `variables.name`，足以覆盖
+// VAP 策略中常见的直接引用写法。匹配后还要求紧跟着的字符不是标识符的一部分
+// （或者就是字符串结尾），否则 `foo` 会被 `variables.foobar` 误判成被引用。
+func referencesVariable(expr, name string) bool {
+	needle := "variables." + name
+	for i := 0; i+len(needle) <= len(expr); i++ {
+		if expr[i:i+len(needle)] != needle {
+			continue
+		}
+		if i+len(needle) == len(expr) || !isIdentifierByte(expr[i+len(needle)]) {
+			return true
+		}
+	}
+	return false
+}
+
+// isIdentifierByte 判断 b 是否可以出现在一个 CEL 标识符里（字母、数字或下划线）。
+func isIdentifierByte(b byte) bool {
+	return b == '_' ||
+		(b >= 'a' && b <= 'z') ||
+		(b >= 'A' && b <= 'Z') ||
+		(b >= '0' && b <= '9')
+}
+
+// Outcome 记录一条 validation 对某个 object 的求值结果。
+type Outcome struct {
+	Validation Validation
+	Passed     bool
+	Message    string
+	Reason     string
+	Error      error
+}
+
+// Result 汇总一个 object 在该策略下 matchConditions 与 validations 的求值情况。
+type Result struct {
+	Matched           bool
+	MatchConditionErr error
+	Outcomes          []Outcome
+}
@@ -0,0 +1,192 @@
+package policy
+
+import "testing"
+
+// TestParseBundleAndEvaluateEndToEnd parses a full VAP-style YAML bundle and
+// evaluates it against sample objects, covering matchConditions gating,
+// variables dependency-order resolution, and message/messageExpression/reason
+// output end to end.
+func TestParseBundleAndEvaluateEndToEnd(t *testing.T) {
+	yamlDoc := []byte(`
+apiVersion: admissionregistration.k8s.io/v1
+kind: ValidatingAdmissionPolicy
+metadata:
+  name: replica-limit
+spec:
+  paramKind:
+    apiVersion: v1
+    kind: ConfigMap
+  variables:
+    - name: limit
+      expression: "params.limit"
+    - name: withinLimit
+      expression: "object.replicas <= variables.limit"
+  matchConditions:
+    - name: onlyDeployments
+      expression: "object.kind == 'Deployment'"
+  validations:
+    - expression: "variables.withinLimit"
+      messageExpression: "'replicas ' + string(object.replicas) + ' exceeds limit ' + string(variables.limit)"
+      reason: "Invalid"
+`)
+
+	bundle, err := ParseBundle(yamlDoc)
+	if err != nil {
+		t.Fatalf("ParseBundle() error = %v", err)
+	}
+
+	env, err := BuildEnv(bundle)
+	if err != nil {
+		t.Fatalf("BuildEnv() error = %v", err)
+	}
+
+	params := map[string]interface{}{"limit": int64(3)}
+
+	t.Run("non-matching object skips validations", func(t *testing.T) {
+		object := map[string]interface{}{"kind": "Pod", "replicas": int64(10)}
+		result, err := Evaluate(env, bundle, object, nil, params)
+		if err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+		if result.Matched {
+			t.Fatal("Matched = true, want false for an object that fails matchConditions")
+		}
+		if len(result.Outcomes) != 0 {
+			t.Errorf("Outcomes = %v, want none when matchConditions are not satisfied", result.Outcomes)
+		}
+	})
+
+	t.Run("passing validation", func(t *testing.T) {
+		object := map[string]interface{}{"kind": "Deployment", "replicas": int64(2)}
+		result, err := Evaluate(env, bundle, object, nil, params)
+		if err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+		if !result.Matched {
+			t.Fatal("Matched = false, want true")
+		}
+		if len(result.Outcomes) != 1 {
+			t.Fatalf("len(Outcomes) = %d, want 1", len(result.Outcomes))
+		}
+		if !result.Outcomes[0].Passed {
+			t.Errorf("Outcomes[0].Passed = false, want true")
+		}
+	})
+
+	t.Run("failing validation resolves messageExpression and reason", func(t *testing.T) {
+		object := map[string]interface{}{"kind": "Deployment", "replicas": int64(10)}
+		result, err := Evaluate(env, bundle, object, nil, params)
+		if err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+		if !result.Matched {
+			t.Fatal("Matched = false, want true")
+		}
+		if len(result.Outcomes) != 1 {
+			t.Fatalf("len(Outcomes) = %d, want 1", len(result.Outcomes))
+		}
+		outcome := result.Outcomes[0]
+		if outcome.Passed {
+			t.Fatal("Passed = true, want false")
+		}
+		if want := "replicas 10 exceeds limit 3"; outcome.Message != want {
+			t.Errorf("Message = %q, want %q", outcome.Message, want)
+		}
+		if outcome.Reason != "Invalid" {
+			t.Errorf("Reason = %q, want %q", outcome.Reason, "Invalid")
+		}
+	})
+}
+
+func TestReferencesVariable(t *testing.T) {
+	cases := []struct {
+		name string
+		expr string
+		want string
+		ref  bool
+	}{
+		{name: "direct reference", expr: `variables.foo == "x"`, want: "foo", ref: true},
+		{name: "no reference", expr: `object.foo == "x"`, want: "foo", ref: false},
+		{name: "prefix collision is not a reference", expr: `variables.foobar == "x"`, want: "foo", ref: false},
+		{name: "reference at end of expression", expr: `object.x == variables.foo`, want: "foo", ref: true},
+		{name: "reference followed by index", expr: `variables.foo[0]`, want: "foo", ref: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := referencesVariable(tc.expr, tc.want); got != tc.ref {
+				t.Errorf("referencesVariable(%q, %q) = %v, want %v", tc.expr, tc.want, got, tc.ref)
+			}
+		})
+	}
+}
+
+func TestOrderedVariablesDependencyOrder(t *testing.T) {
+	vars := []Variable{
+		{Name: "b", Expression: "variables.a + 1"},
+		{Name: "a", Expression: "1"},
+	}
+
+	ordered, err := OrderedVariables(vars)
+	if err != nil {
+		t.Fatalf("OrderedVariables() error = %v", err)
+	}
+	if len(ordered) != 2 || ordered[0].Name != "a" || ordered[1].Name != "b" {
+		t.Fatalf("OrderedVariables() = %v, want [a b]", ordered)
+	}
+}
+
+func TestOrderedVariablesNoFalseDependency(t *testing.T) {
+	// x references variables.foobar, not variables.foo, even though "foo" is a
+	// prefix of "foobar"; treating it as a dependency on foo would move foo
+	// ahead of x.
+	vars := []Variable{
+		{Name: "foobar", Expression: "1"},
+		{Name: "x", Expression: "variables.foobar + 1"},
+		{Name: "foo", Expression: "2"},
+	}
+
+	ordered, err := OrderedVariables(vars)
+	if err != nil {
+		t.Fatalf("OrderedVariables() error = %v", err)
+	}
+	if len(ordered) != 3 || ordered[0].Name != "foobar" || ordered[1].Name != "x" || ordered[2].Name != "foo" {
+		t.Fatalf("OrderedVariables() = %v, want [foobar x foo]", ordered)
+	}
+}
+
+func TestOrderedVariablesCircular(t *testing.T) {
+	vars := []Variable{
+		{Name: "a", Expression: "variables.b"},
+		{Name: "b", Expression: "variables.a"},
+	}
+
+	if _, err := OrderedVariables(vars); err == nil {
+		t.Fatal("OrderedVariables() error = nil, want circular reference error")
+	}
+}
+
+func TestBuildEnvParamsDeclaredOnlyWithParamKind(t *testing.T) {
+	withoutParamKind := &Bundle{Spec: Spec{
+		Validations: []Validation{{Expression: "true"}},
+	}}
+	env, err := BuildEnv(withoutParamKind)
+	if err != nil {
+		t.Fatalf("BuildEnv() error = %v", err)
+	}
+	if _, issues := env.Compile("params.replicas"); issues == nil || issues.Err() == nil {
+		t.Error("expected compiling a `params` reference to fail without a declared paramKind")
+	}
+
+	withParamKind := &Bundle{Spec: Spec{
+		ParamKind:   &ParamKind{APIVersion: "v1", Kind: "ConfigMap"},
+		Validations: []Validation{{Expression: "true"}},
+	}}
+	env, err = BuildEnv(withParamKind)
+	if err != nil {
+		t.Fatalf("BuildEnv() error = %v", err)
+	}
+	if _, issues := env.Compile("params.replicas"); issues != nil && issues.Err() != nil {
+		t.Errorf("expected compiling a `params` reference to succeed with a declared paramKind, got: %v", issues.Err())
+	}
+}
@@ -0,0 +1,230 @@
+// Package sources 把 object 输入文件的读取从固定的"多文档 YAML"泛化成按扩展名
+// 与内容自动识别格式：多文档 YAML、JSON 数组、JSONL/NDJSON，外加一个不需要把
+// 整个输入读进内存的流式读取路径，供 --stream 模式使用。path 传 "-" 表示从
+// stdin 读取。
+package sources
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Format 标识一份输入是按哪种方式编码的。
+type Format int
+
+const (
+	FormatYAML Format = iota
+	FormatJSONArray
+	FormatJSONL
+)
+
+// DetectFormat 先看扩展名，扩展名不认识时再按内容首字符猜测：`[` 当成 JSON
+// 数组，`{` 当成 FormatJSONL（一串首尾相接的 JSON 值——见 parseJSONL/streamJSONL
+// 的实现，这个名字底下其实覆盖了单个 JSON 对象、经典的逐行 JSONL 以及两者的
+// 混合，而不只是字面意义上的"每行一个对象"），其余一律当作多文档 YAML。
+// `.json` 扩展名不能直接认定是数组：最常见的 `.json` 文件其实只装了单个
+// 对象，因此跟裸内容探测一样，要看首字符才能决定是数组还是对象。
+func DetectFormat(path string, sniff []byte) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		if looksLikeJSONArray(sniff) {
+			return FormatJSONArray
+		}
+		return FormatJSONL
+	case ".jsonl", ".ndjson":
+		return FormatJSONL
+	case ".yaml", ".yml":
+		return FormatYAML
+	}
+
+	trimmed := bytes.TrimLeft(sniff, " \t\r\n")
+	switch {
+	case len(trimmed) > 0 && trimmed[0] == '[':
+		return FormatJSONArray
+	case len(trimmed) > 0 && trimmed[0] == '{':
+		return FormatJSONL
+	default:
+		return FormatYAML
+	}
+}
+
+// looksLikeJSONArray 判断探测到的内容开头（忽略前导空白）是不是 `[`。
+func looksLikeJSONArray(sniff []byte) bool {
+	trimmed := bytes.TrimLeft(sniff, " \t\r\n")
+	return len(trimmed) > 0 && trimmed[0] == '['
+}
+
+// readAll 读取 path 的全部内容，"-" 表示 stdin。
+func readAll(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// Load 读取并解析整份 object 输入，自动识别格式后返回全部 object。
+func Load(path string) ([]map[string]interface{}, error) {
+	data, err := readAll(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q: %w", path, err)
+	}
+
+	sniffLen := 512
+	if len(data) < sniffLen {
+		sniffLen = len(data)
+	}
+
+	switch DetectFormat(path, data[:sniffLen]) {
+	case FormatJSONArray:
+		return parseJSONArray(data)
+	case FormatJSONL:
+		return parseJSONL(data)
+	default:
+		return parseYAMLDocuments(data)
+	}
+}
+
+func parseYAMLDocuments(data []byte) ([]map[string]interface{}, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	var documents []map[string]interface{}
+	for {
+		var doc map[string]interface{}
+		err := decoder.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, doc)
+	}
+	return documents, nil
+}
+
+func parseJSONArray(data []byte) ([]map[string]interface{}, error) {
+	var docs []map[string]interface{}
+	if err := json.Unmarshal(data, &docs); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON array: %w", err)
+	}
+	return docs, nil
+}
+
+// parseJSONL 解析一串首尾相接的 JSON 值：用 json.Decoder 按值而不是按行切分，
+// 所以经典的逐行 JSONL/NDJSON、单个跨多行 pretty-print 的 JSON 对象，或是两者
+// 的混合都能正确处理——不会像按行 Scanner 那样，一旦某个对象的格式化跨越多行
+// 就把半个对象当成一整行去解析。
+func parseJSONL(data []byte) ([]map[string]interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var docs []map[string]interface{}
+	for {
+		var doc map[string]interface{}
+		err := dec.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse JSON value %d: %w", len(docs)+1, err)
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// StreamFunc 在 --stream 模式下对每个解析出的 object 依次调用，index 从 0 开始。
+type StreamFunc func(index int, object map[string]interface{}) error
+
+// Stream 增量地读取 path（"-" 表示 stdin），边解析边调用 fn，不需要把整份输入
+// 读进内存：YAML 多文档与 JSONL/NDJSON 天然逐条读取，JSON 数组用
+// encoding/json 的 token 流式拆开每个元素。
+func Stream(path string, fn StreamFunc) error {
+	var base io.Reader
+	closeFn := func() error { return nil }
+	if path == "-" {
+		base = os.Stdin
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open %q: %w", path, err)
+		}
+		base = f
+		closeFn = f.Close
+	}
+	defer closeFn()
+
+	br := bufio.NewReader(base)
+	sniff, _ := br.Peek(512)
+
+	switch DetectFormat(path, sniff) {
+	case FormatJSONArray:
+		return streamJSONArray(br, fn)
+	case FormatJSONL:
+		return streamJSONL(br, fn)
+	default:
+		return streamYAML(br, fn)
+	}
+}
+
+func streamYAML(r io.Reader, fn StreamFunc) error {
+	decoder := yaml.NewDecoder(r)
+	for i := 0; ; i++ {
+		var doc map[string]interface{}
+		err := decoder.Decode(&doc)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(i, doc); err != nil {
+			return err
+		}
+	}
+}
+
+// streamJSONL 跟 parseJSONL 一样按值而不是按行解码，增量地把每个 JSON 值喂给
+// fn，不需要预先知道某个对象的 pretty-print 会跨几行。
+func streamJSONL(r io.Reader, fn StreamFunc) error {
+	dec := json.NewDecoder(r)
+	for index := 0; ; index++ {
+		var doc map[string]interface{}
+		err := dec.Decode(&doc)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse JSON value %d: %w", index+1, err)
+		}
+		if err := fn(index, doc); err != nil {
+			return err
+		}
+	}
+}
+
+func streamJSONArray(r io.Reader, fn StreamFunc) error {
+	dec := json.NewDecoder(r)
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("expected a JSON array")
+	}
+	for i := 0; dec.More(); i++ {
+		var doc map[string]interface{}
+		if err := dec.Decode(&doc); err != nil {
+			return fmt.Errorf("failed to parse JSON array element %d: %w", i+1, err)
+		}
+		if err := fn(i, doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
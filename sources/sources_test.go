@@ -0,0 +1,78 @@
+package sources
+
+import (
+	"os"
+	"testing"
+)
+
+func TestDetectFormat(t *testing.T) {
+	cases := []struct {
+		name string
+		path string
+		sniff string
+		want Format
+	}{
+		{name: "json array extension", path: "objects.json", sniff: `[{"a":1}]`, want: FormatJSONArray},
+		{name: "json single object extension", path: "objects.json", sniff: `{"a":1}`, want: FormatJSONL},
+		{name: "json pretty single object extension", path: "objects.json", sniff: "{\n  \"a\": 1\n}", want: FormatJSONL},
+		{name: "jsonl extension", path: "objects.jsonl", sniff: `{"a":1}`, want: FormatJSONL},
+		{name: "yaml extension", path: "objects.yaml", sniff: "a: 1", want: FormatYAML},
+		{name: "sniffed array", path: "objects", sniff: `[{"a":1}]`, want: FormatJSONArray},
+		{name: "sniffed single object", path: "objects", sniff: `{"a":1}`, want: FormatJSONL},
+		{name: "sniffed yaml", path: "objects", sniff: "a: 1", want: FormatYAML},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := DetectFormat(tc.path, []byte(tc.sniff)); got != tc.want {
+				t.Errorf("DetectFormat(%q, %q) = %v, want %v", tc.path, tc.sniff, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseJSONLSingleObject(t *testing.T) {
+	// A lone, pretty-printed JSON object spans multiple lines but must still
+	// parse as exactly one document, not fail per-line like a naive scanner would.
+	data := []byte("{\n  \"name\": \"a\",\n  \"replicas\": 3\n}")
+	docs, err := parseJSONL(data)
+	if err != nil {
+		t.Fatalf("parseJSONL() error = %v", err)
+	}
+	if len(docs) != 1 {
+		t.Fatalf("len(docs) = %d, want 1", len(docs))
+	}
+	if docs[0]["name"] != "a" {
+		t.Errorf("docs[0][\"name\"] = %v, want %q", docs[0]["name"], "a")
+	}
+}
+
+func TestParseJSONLCompactLines(t *testing.T) {
+	data := []byte(`{"name":"a"}
+{"name":"b"}
+{"name":"c"}
+`)
+	docs, err := parseJSONL(data)
+	if err != nil {
+		t.Fatalf("parseJSONL() error = %v", err)
+	}
+	if len(docs) != 3 {
+		t.Fatalf("len(docs) = %d, want 3", len(docs))
+	}
+}
+
+func TestLoadSingleJSONObjectFile(t *testing.T) {
+	dir := t.TempDir()
+	path := dir + "/object.json"
+	if err := os.WriteFile(path, []byte("{\n  \"name\": \"a\"\n}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	docs, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(docs) != 1 || docs[0]["name"] != "a" {
+		t.Fatalf("Load() = %v, want a single object with name=a", docs)
+	}
+}
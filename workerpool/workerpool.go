@@ -0,0 +1,82 @@
+// Package workerpool 提供 --workers 控制的并行求值原语：按下标把一组任务分发给
+// 固定数量的 goroutine 并发处理，并始终按下标重新拼回原始顺序，供普通模式、
+// --benchmark 与 --stream 三种求值路径共用；同时提供一个 activation map 的
+// sync.Pool，避免高并发求值下频繁分配 map[string]interface{}。
+package workerpool
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Workers 把用户传入的 n 规整为一个合法的 worker 数：n<=0 时回退到
+// runtime.NumCPU()，且不超过 tasks（tasks<=0 表示任务数未知，不做这个限制）。
+func Workers(n, tasks int) int {
+	if n <= 0 {
+		n = runtime.NumCPU()
+	}
+	if tasks > 0 && n > tasks {
+		n = tasks
+	}
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// Map 用 workers 个 goroutine 并发对 [0,n) 范围内的每个下标调用 fn，返回的切片
+// 始终按下标排序，与 goroutine 的调度/完成顺序无关。
+func Map(workers, n int, fn func(i int) interface{}) []interface{} {
+	workers = Workers(workers, n)
+	results := make([]interface{}, n)
+	if n == 0 {
+		return results
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// ActivationPool 复用传给 cel.Program.Eval 的 activation map，减少并发求值下的
+// 分配压力；cel.Program 本身可安全并发调用，瓶颈只在调用方重复分配 map。
+type ActivationPool struct {
+	pool sync.Pool
+}
+
+// NewActivationPool 创建一个池，按需分配容量为 2 的 map（对应固定的
+// object/params 两个变量）。
+func NewActivationPool() *ActivationPool {
+	return &ActivationPool{
+		pool: sync.Pool{
+			New: func() interface{} { return make(map[string]interface{}, 2) },
+		},
+	}
+}
+
+// Get 取出一个可复用的 activation map；Put 放回前已清空，因此取出的 map 总是空的。
+func (p *ActivationPool) Get() map[string]interface{} {
+	return p.pool.Get().(map[string]interface{})
+}
+
+// Put 清空 m 后放回池里。
+func (p *ActivationPool) Put(m map[string]interface{}) {
+	for k := range m {
+		delete(m, k)
+	}
+	p.pool.Put(m)
+}
@@ -0,0 +1,74 @@
+package workerpool
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestWorkers(t *testing.T) {
+	numCPU := runtime.NumCPU()
+	if numCPU < 1 {
+		numCPU = 1
+	}
+
+	cases := []struct {
+		name  string
+		n     int
+		tasks int
+		want  int
+	}{
+		{name: "n<=0 falls back to NumCPU", n: 0, tasks: 0, want: numCPU},
+		{name: "clamped to task count", n: 8, tasks: 3, want: 3},
+		{name: "unknown task count is not clamped", n: 8, tasks: 0, want: 8},
+		{name: "negative task count is not clamped", n: 8, tasks: -1, want: 8},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Workers(tc.n, tc.tasks); got != tc.want {
+				t.Errorf("Workers(%d, %d) = %d, want %d", tc.n, tc.tasks, got, tc.want)
+			}
+		})
+	}
+}
+
+// TestMapPreservesOrder makes sure the result slice is indexed by task index
+// regardless of which goroutine finishes first: later-indexed jobs are made
+// to sleep less than earlier ones, so without the reassembly step the wrong
+// order would show up if goroutines were left to write straight to output.
+func TestMapPreservesOrder(t *testing.T) {
+	const n = 50
+	results := Map(8, n, func(i int) interface{} {
+		time.Sleep(time.Duration(n-i) * time.Millisecond / 10)
+		return i
+	})
+
+	if len(results) != n {
+		t.Fatalf("len(results) = %d, want %d", len(results), n)
+	}
+	for i, r := range results {
+		if r.(int) != i {
+			t.Fatalf("results[%d] = %v, want %d", i, r, i)
+		}
+	}
+}
+
+func TestMapEmpty(t *testing.T) {
+	results := Map(4, 0, func(i int) interface{} { return i })
+	if len(results) != 0 {
+		t.Fatalf("len(results) = %d, want 0", len(results))
+	}
+}
+
+func TestActivationPoolPutClearsMap(t *testing.T) {
+	pool := NewActivationPool()
+	m := pool.Get()
+	m["object"] = map[string]interface{}{"a": 1}
+	pool.Put(m)
+
+	m2 := pool.Get()
+	if len(m2) != 0 {
+		t.Errorf("reused activation map is not empty: %v", m2)
+	}
+}